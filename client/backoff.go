@@ -0,0 +1,115 @@
+package client
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	"github.com/open-telemetry/opamp-go/client/internal"
+)
+
+// DefaultBackoffResetAfter is the default of BackoffSettings.ResetAfter.
+const DefaultBackoffResetAfter = time.Minute
+
+// BackoffSettings define how long the client waits before trying again after
+// it fails to connect to the server or to send a message to it. The intervals
+// grow exponentially and are randomized, so that many agents that lose the
+// connection at the same time (e.g. when the server restarts) do not try to
+// reconnect all at once. If the server asks the client to retry after some
+// time (e.g. via the Retry-After header) the client waits at least that long.
+type BackoffSettings struct {
+	// The interval before the first retry. If 0, the default of 500ms is used.
+	InitialInterval time.Duration
+
+	// The maximum interval. If 0, the default of 60 seconds is used.
+	MaxInterval time.Duration
+
+	// The factor by which the interval grows after each failed attempt.
+	// If 0, the default of 1.5 is used.
+	Multiplier float64
+
+	// The randomization of the intervals. If 0 (the default), "full jitter" is
+	// used: each interval is chosen randomly between 0 and the current
+	// interval. If positive, each interval is chosen randomly between
+	// interval*(1-RandomizationFactor) and interval*(1+RandomizationFactor).
+	// If negative, the intervals are not randomized.
+	RandomizationFactor float64
+
+	// The policy to use instead of the one defined by the fields above. The
+	// client never gives up: if the policy returns backoff.Stop it is reset.
+	Policy backoff.BackOff
+
+	// The policy is reset, i.e. the intervals start from the initial interval
+	// again, when a connection that was up for at least ResetAfter is lost.
+	// When using the HTTP transport the policy is reset after every successful
+	// request. If 0, DefaultBackoffResetAfter is used.
+	ResetAfter time.Duration
+}
+
+// newRetryBackoff creates the backoff that the client uses for retries.
+func newRetryBackoff(settings BackoffSettings, clock internal.Clock) *internal.RetryBackoff {
+	policy := settings.Policy
+	if policy == nil {
+		exp := backoff.NewExponentialBackOff()
+		exp.Clock = clock
+		// Make it retry forever.
+		exp.MaxElapsedTime = 0
+		if settings.InitialInterval > 0 {
+			exp.InitialInterval = settings.InitialInterval
+		}
+		if settings.MaxInterval > 0 {
+			exp.MaxInterval = settings.MaxInterval
+		}
+		if settings.Multiplier > 0 {
+			exp.Multiplier = settings.Multiplier
+		}
+		switch {
+		case settings.RandomizationFactor > 0:
+			exp.RandomizationFactor = settings.RandomizationFactor
+			policy = exp
+		case settings.RandomizationFactor < 0:
+			exp.RandomizationFactor = 0
+			policy = exp
+		default:
+			exp.RandomizationFactor = 0
+			policy = newFullJitterBackOff(exp)
+		}
+		exp.Reset()
+	}
+
+	resetAfter := settings.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = DefaultBackoffResetAfter
+	}
+	return internal.NewRetryBackoff(policy, clock, resetAfter)
+}
+
+// fullJitterBackOff chooses each interval randomly between 0 and the interval
+// returned by the underlying backoff.
+type fullJitterBackOff struct {
+	backoff.BackOff
+
+	// Seeded separately for each client, so that the agents started at the
+	// same time do not get the same sequence of intervals.
+	random      *rand.Rand
+	randomMutex sync.Mutex
+}
+
+func newFullJitterBackOff(b backoff.BackOff) *fullJitterBackOff {
+	return &fullJitterBackOff{
+		BackOff: b,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (b *fullJitterBackOff) NextBackOff() time.Duration {
+	interval := b.BackOff.NextBackOff()
+	if interval == backoff.Stop || interval <= 0 {
+		return interval
+	}
+	b.randomMutex.Lock()
+	defer b.randomMutex.Unlock()
+	return time.Duration(b.random.Int63n(int64(interval) + 1))
+}
@@ -8,8 +8,8 @@ import (
 )
 
 type CallbacksStruct struct {
-	OnConnectFunc       func()
-	OnConnectFailedFunc func(err error)
+	OnConnectFunc       func(serverURL string)
+	OnConnectFailedFunc func(serverURL string, err error)
 	OnErrorFunc         func(err *protobufs.ServerErrorResponse)
 
 	OnRemoteConfigFunc func(
@@ -38,20 +38,24 @@ type CallbacksStruct struct {
 	) error
 
 	OnAddonsAvailableFunc       func(ctx context.Context, addons *protobufs.AddonsAvailable, syncer types.AddonSyncer) error
-	OnAgentPackageAvailableFunc func(addons *protobufs.AgentPackageAvailable, syncer types.AgentPackageSyncer) error
+	OnAgentPackageAvailableFunc func(
+		ctx context.Context,
+		packageAvailable *protobufs.AgentPackageAvailable,
+		syncer types.AgentPackageSyncer,
+	) error
 }
 
 var _ types.Callbacks = (*CallbacksStruct)(nil)
 
-func (c CallbacksStruct) OnConnect() {
+func (c CallbacksStruct) OnConnect(serverURL string) {
 	if c.OnConnectFunc != nil {
-		c.OnConnectFunc()
+		c.OnConnectFunc(serverURL)
 	}
 }
 
-func (c CallbacksStruct) OnConnectFailed(err error) {
+func (c CallbacksStruct) OnConnectFailed(serverURL string, err error) {
 	if c.OnConnectFailedFunc != nil {
-		c.OnConnectFailedFunc(err)
+		c.OnConnectFailedFunc(serverURL, err)
 	}
 }
 
@@ -116,10 +120,12 @@ func (c CallbacksStruct) OnAddonsAvailable(
 }
 
 func (c CallbacksStruct) OnAgentPackageAvailable(
-	addons *protobufs.AgentPackageAvailable, syncer types.AgentPackageSyncer,
+	ctx context.Context,
+	packageAvailable *protobufs.AgentPackageAvailable,
+	syncer types.AgentPackageSyncer,
 ) error {
 	if c.OnAgentPackageAvailableFunc != nil {
-		return c.OnAgentPackageAvailableFunc(addons, syncer)
+		return c.OnAgentPackageAvailableFunc(ctx, packageAvailable, syncer)
 	}
 	return nil
 }
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"context"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// Capabilities are the capabilities advertised by the agent and by the server.
+type Capabilities struct {
+	// The capabilities that the client advertises to the server on behalf of
+	// the agent.
+	Agent protobufs.AgentCapabilities
+
+	// The capabilities advertised by the server. UnspecifiedServerCapability
+	// until the first message from the server that advertises them is received.
+	Server protobufs.ServerCapabilities
+}
+
+// callbackCapabilities are the capabilities that require the agent to implement
+// the corresponding callbacks.
+const callbackCapabilities = protobufs.AgentCapabilities_AcceptsRemoteConfig |
+	protobufs.AgentCapabilities_ReportsEffectiveConfig |
+	protobufs.AgentCapabilities_AcceptsAddons |
+	protobufs.AgentCapabilities_ReportsAddonsStatus |
+	protobufs.AgentCapabilities_AcceptsAgentPackage |
+	protobufs.AgentCapabilities_ReportsAgentPackageStatus |
+	protobufs.AgentCapabilities_ReportsOwnTraces |
+	protobufs.AgentCapabilities_ReportsOwnMetrics |
+	protobufs.AgentCapabilities_ReportsOwnLogs |
+	protobufs.AgentCapabilities_AcceptsOpAMPConnectionSettings |
+	protobufs.AgentCapabilities_AcceptsOtherConnectionSettings
+
+// agentCapabilities returns the capabilities of the agent. If the settings
+// specify the capabilities explicitly they are used as is, otherwise the
+// capabilities are derived from the callbacks and state providers that are
+// supplied in the settings.
+func agentCapabilities(settings *StartSettings) protobufs.AgentCapabilities {
+	// All agents report status.
+	caps := protobufs.AgentCapabilities_ReportsStatus
+
+	if settings.Capabilities != protobufs.AgentCapabilities_UnspecifiedAgentCapability {
+		return caps | settings.Capabilities
+	}
+
+	switch callbacks := settings.Callbacks.(type) {
+	case nil:
+	case CallbacksStruct:
+		caps |= callbacksStructCapabilities(&callbacks)
+	case *CallbacksStruct:
+		caps |= callbacksStructCapabilities(callbacks)
+	default:
+		// A custom implementation implements all callbacks.
+		caps |= callbackCapabilities
+	}
+
+	if settings.LastEffectiveConfig != nil {
+		caps |= protobufs.AgentCapabilities_ReportsEffectiveConfig
+	}
+	if settings.AddonStateProvider != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsAddons |
+			protobufs.AgentCapabilities_ReportsAddonsStatus
+	}
+	if settings.AgentPackageStateProvider != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsAgentPackage |
+			protobufs.AgentCapabilities_ReportsAgentPackageStatus
+	}
+
+	return caps
+}
+
+// callbacksStructCapabilities returns the capabilities that correspond to the
+// callback funcs that are set.
+func callbacksStructCapabilities(c *CallbacksStruct) protobufs.AgentCapabilities {
+	var caps protobufs.AgentCapabilities
+	if c.OnRemoteConfigFunc != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsRemoteConfig |
+			protobufs.AgentCapabilities_ReportsEffectiveConfig
+	}
+	if c.OnAddonsAvailableFunc != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsAddons |
+			protobufs.AgentCapabilities_ReportsAddonsStatus
+	}
+	if c.OnAgentPackageAvailableFunc != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsAgentPackage |
+			protobufs.AgentCapabilities_ReportsAgentPackageStatus
+	}
+	if c.OnOwnTelemetryConnectionSettingsFunc != nil {
+		caps |= protobufs.AgentCapabilities_ReportsOwnTraces |
+			protobufs.AgentCapabilities_ReportsOwnMetrics |
+			protobufs.AgentCapabilities_ReportsOwnLogs
+	}
+	if c.OnOpampConnectionSettingsFunc != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsOpAMPConnectionSettings
+	}
+	if c.OnOtherConnectionSettingsFunc != nil {
+		caps |= protobufs.AgentCapabilities_AcceptsOtherConnectionSettings
+	}
+	return caps
+}
+
+// withStateProviders returns the callbacks that sync the addons and the agent
+// package using the state providers from the settings if the corresponding
+// callbacks are not supplied.
+func withStateProviders(settings *StartSettings) types.Callbacks {
+	if settings.AddonStateProvider == nil && settings.AgentPackageStateProvider == nil {
+		return settings.Callbacks
+	}
+
+	var c CallbacksStruct
+	switch callbacks := settings.Callbacks.(type) {
+	case nil:
+	case CallbacksStruct:
+		c = callbacks
+	case *CallbacksStruct:
+		c = *callbacks
+	default:
+		// A custom implementation implements all callbacks.
+		return settings.Callbacks
+	}
+
+	if c.OnAddonsAvailableFunc == nil && settings.AddonStateProvider != nil {
+		provider := settings.AddonStateProvider
+		c.OnAddonsAvailableFunc = func(
+			ctx context.Context, _ *protobufs.AddonsAvailable, syncer types.AddonSyncer,
+		) error {
+			return syncer.Sync(ctx, provider)
+		}
+	}
+	if c.OnAgentPackageAvailableFunc == nil && settings.AgentPackageStateProvider != nil {
+		provider := settings.AgentPackageStateProvider
+		c.OnAgentPackageAvailableFunc = func(
+			ctx context.Context, _ *protobufs.AgentPackageAvailable, syncer types.AgentPackageSyncer,
+		) error {
+			return syncer.Sync(ctx, provider)
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+)
+
+// tokenExpiryDelta is how long before the expiry the tokens are considered
+// expired, so that a token does not expire while the request is in flight.
+const tokenExpiryDelta = 10 * time.Second
+
+var errNoAccessToken = errors.New("token response has no access_token")
+
+// ClientCredentialsConfig is the configuration of the OAuth2 client credentials
+// grant, see NewClientCredentialsTokenSource.
+type ClientCredentialsConfig struct {
+	// The URL of the token endpoint of the authorization server.
+	TokenURL string
+
+	ClientID     string
+	ClientSecret string
+
+	// The scopes to request. Optional.
+	Scopes []string
+
+	// Additional parameters of the token request, e.g. "audience". Optional.
+	EndpointParams url.Values
+
+	// The HTTP client to make the token requests with. If nil
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// clientCredentialsTokenSource obtains the tokens using the OAuth2 client
+// credentials grant and caches them until they expire.
+type clientCredentialsTokenSource struct {
+	config ClientCredentialsConfig
+
+	// The cached token. Nil if there is none. Protected by mutex, which is
+	// held while the token is obtained, so that concurrent Token calls do not
+	// make multiple token requests.
+	token *types.Token
+	mutex sync.Mutex
+}
+
+// NewClientCredentialsTokenSource returns a TokenSource that obtains the tokens
+// from the authorization server using the OAuth2 client credentials grant
+// (RFC 6749, section 4.4). The client authenticates with HTTP Basic
+// authentication. The token is cached until shortly before it expires.
+func NewClientCredentialsTokenSource(config ClientCredentialsConfig) types.TokenSource {
+	return &clientCredentialsTokenSource{config: config}
+}
+
+func (s *clientCredentialsTokenSource) Token(ctx context.Context) (*types.Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token != nil &&
+		(s.token.Expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(s.token.Expiry)) {
+		return s.token, nil
+	}
+
+	token, err := s.requestToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.token = token
+	return token, nil
+}
+
+func (s *clientCredentialsTokenSource) Invalidate() {
+	s.mutex.Lock()
+	s.token = nil
+	s.mutex.Unlock()
+}
+
+// tokenResponse is the response of the token endpoint, see RFC 6749, sections
+// 5.1 and 5.2.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// requestToken requests a new token from the token endpoint.
+func (s *clientCredentialsTokenSource) requestToken(ctx context.Context) (*types.Token, error) {
+	params := url.Values{}
+	for k, v := range s.config.EndpointParams {
+		params[k] = v
+	}
+	params.Set("grant_type", "client_credentials")
+	if len(s.config.Scopes) > 0 {
+		params.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, s.config.TokenURL, strings.NewReader(params.Encode()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// The credentials are form-encoded before Base64, see RFC 6749, section 2.3.1.
+	req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+
+	httpClient := s.config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read token response: %w", err)
+	}
+	var tr tokenResponse
+	jsonErr := json.Unmarshal(body, &tr)
+
+	if resp.StatusCode != http.StatusOK {
+		if jsonErr == nil && tr.Error != "" {
+			return nil, fmt.Errorf(
+				"token request failed: %s: %s %s", resp.Status, tr.Error, tr.ErrorDescription,
+			)
+		}
+		return nil, fmt.Errorf("token request failed: %s", resp.Status)
+	}
+	if jsonErr != nil {
+		return nil, fmt.Errorf("cannot decode token response: %w", jsonErr)
+	}
+	if tr.AccessToken == "" {
+		return nil, errNoAccessToken
+	}
+
+	token := &types.Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
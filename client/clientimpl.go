@@ -3,19 +3,18 @@ package client
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
-	"net/url"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
 	"github.com/gorilla/websocket"
 
 	"github.com/open-telemetry/opamp-go/client/internal"
 	"github.com/open-telemetry/opamp-go/client/types"
 	sharedinternal "github.com/open-telemetry/opamp-go/internal"
+	"github.com/open-telemetry/opamp-go/logging"
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
@@ -23,27 +22,49 @@ var (
 	errAlreadyStarted          = errors.New("already started")
 	errCannotStopNotStarted    = errors.New("cannot stop because not started")
 	errAgentDescriptionMissing = errors.New("AgentDescription is not set")
+	errInstanceUidMissing      = errors.New("InstanceUid is not set")
 )
 
 // client is a Client implementation.
 type client struct {
-	logger   types.Logger
+	// The logger passed to New() and the logger that adds the instance UID
+	// of the agent to the messages, set by Start().
+	baseLogger logging.Logger
+	logger     logging.Logger
+
 	settings StartSettings
 
-	// OpAMP Server URL.
-	url *url.URL
+	// Settings to use when connecting to OpAMP Server.
+	connSettings connectionSettings
 
-	// HTTP request headers to use when connecting to OpAMP Server.
-	requestHeader http.Header
+	// When the client failed over from the primary endpoint or last tried to
+	// fail back to it. Protected by connMutex.
+	failedOverAt time.Time
 
 	// Websocket dialer and connection.
 	dialer    websocket.Dialer
 	conn      *websocket.Conn
 	connMutex sync.RWMutex
 
-	// True if Start() is successful.
+	// The sender that sends messages over conn. Nil if not connected.
+	// Protected by connMutex.
+	wsSender *internal.WSSender
+
+	// A connection that was established using newly offered connection settings
+	// and which will be used instead of connecting again next time the client
+	// needs to connect. Protected by connMutex.
+	pendingConn *websocket.Conn
+
+	// The capabilities advertised to the server. Protected by connMutex.
+	capabilities protobufs.AgentCapabilities
+
+	// True if Start() is successful and Stop() did not finish stopping yet.
+	// Protected by startStopMutex.
 	isStarted bool
 
+	// Serializes Start() and Stop().
+	startStopMutex sync.Mutex
+
 	// Cancellation func background running go routines.
 	runCancel context.CancelFunc
 
@@ -51,65 +72,180 @@ type client struct {
 	isStoppingFlag  bool
 	isStoppingMutex sync.RWMutex
 
-	// Indicates that the Client is fully stopped.
+	// Closed when the goroutines started by Start() are stopped.
 	stoppedSignal chan struct{}
 
-	// The sender is responsible for sending portion of the OpAMP protocol.
+	// The sender keeps the messages that are pending to be sent to the server.
 	sender *internal.Sender
+
+	// Saves the state when StartSettings.StateStore is set, nil otherwise.
+	stateSaver *stateSaver
+
+	// Runs the callbacks that process the messages received from the server.
+	dispatcher *internal.CallbackDispatcher
+
+	// The state of the connection and the traffic statistics.
+	status *internal.StatusTracker
+
+	// Sends the messages when using HTTP transport. Nil if WebSocket transport
+	// is used.
+	httpSender *internal.HTTPSender
+
+	// Set if the server asked us to reconnect later. Used by the next
+	// ensureConnected() call. Only accessed from runUntilStopped goroutine.
+	retryAfter internal.OptionalDuration
+
+	// Set after the first connection is lost. The reconnection attempts are
+	// delayed. Only accessed from runUntilStopped goroutine.
+	reconnecting bool
+
+	// The backoff for the connection attempts and, when using HTTP transport,
+	// for the retries of the requests.
+	backoff *internal.RetryBackoff
+
+	// The clock used for the backoff. Replaced by the tests.
+	clock internal.Clock
 }
 
 var _ OpAMPClient = (*client)(nil)
 
-const retryAfterHTTPHeader = "Retry-After"
-
+// New creates a client that writes the log messages to the specified
+// printf-style logger. If the logger also implements logging.Logger the
+// messages are written to it as structured messages. Nothing is logged if the
+// logger is nil.
 func New(logger types.Logger) *client {
 	if logger == nil {
-		logger = &sharedinternal.NopLogger{}
+		return NewWithLogger(nil)
+	}
+	if structured, ok := logger.(logging.Logger); ok {
+		return NewWithLogger(structured)
+	}
+	return NewWithLogger(logging.FromPrintf(logger))
+}
+
+// NewWithLogger creates a client that writes the log messages to the specified
+// structured logger. Nothing is logged if the logger is nil.
+func NewWithLogger(logger logging.Logger) *client {
+	if logger == nil {
+		logger = logging.NopLogger{}
 	}
 
 	w := &client{
-		logger:        logger,
-		stoppedSignal: make(chan struct{}, 1),
-		sender:        internal.NewSender(logger),
+		baseLogger: logger,
+		logger:     logger,
+		sender:     internal.NewSender(),
+		status:     internal.NewStatusTracker(),
+		clock:      internal.SystemClock,
 	}
 	return w
 }
 
+// validateStartSettings verifies the settings passed to Start().
+func validateStartSettings(settings *StartSettings) error {
+	if settings.AgentDescription == nil {
+		return errAgentDescriptionMissing
+	}
+	// With the StateStore the InstanceUid is loaded or generated by Start().
+	if settings.InstanceUid == "" && settings.StateStore == nil {
+		return errInstanceUidMissing
+	}
+
+	serverURLs := append([]string{settings.OpAMPServerURL}, settings.FailoverServerURLs...)
+	for _, serverURL := range serverURLs {
+		if _, err := parseSupportedServerURL(serverURL, settings.TLSConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *client) Start(settings StartSettings) error {
+	w.startStopMutex.Lock()
+	defer w.startStopMutex.Unlock()
+
 	if w.isStarted {
 		return errAlreadyStarted
 	}
 
-	if settings.AgentDescription == nil {
-		return errAgentDescriptionMissing
+	if err := validateStartSettings(&settings); err != nil {
+		return err
 	}
 
-	w.settings = settings
+	// Discard the state of the previous run, if any.
+	w.logger = w.baseLogger
+	w.dispatcher = internal.NewCallbackDispatcher()
+	w.stoppedSignal = make(chan struct{})
+	w.httpSender = nil
+	w.retryAfter = internal.OptionalDuration{}
+	w.reconnecting = false
+	w.isStoppingMutex.Lock()
+	w.isStoppingFlag = false
+	w.isStoppingMutex.Unlock()
+	// The capabilities of the server are learned again after connecting.
+	w.sender.SetServerCapabilities(protobufs.ServerCapabilities_UnspecifiedServerCapability)
 
-	var err error
+	w.settings = settings
+	w.settings.Callbacks = withStateProviders(&w.settings)
+	capabilities := agentCapabilities(&w.settings)
+
+	var fullStateListener func(fullState *protobufs.AgentToServer)
+	w.stateSaver = nil
+	if w.settings.StateStore != nil {
+		loaded, err := loadState(w.logger, &w.settings)
+		if err != nil {
+			return err
+		}
+		w.stateSaver = newStateSaver(w.logger, w.settings.StateStore, w.settings.InstanceUid, loaded)
+		fullStateListener = w.stateSaver.update
+	}
+	// Replaces the listener set by the previous Start(), if any.
+	w.sender.SetFullStateListener(fullStateListener)
+	w.logger = w.baseLogger.With(logging.InstanceUid(w.settings.InstanceUid))
 
 	// Prepare server connection settings.
-	w.url, err = url.Parse(w.settings.OpAMPServerURL)
+	connSettings, err := newConnectionSettings(&w.settings)
 	if err != nil {
 		return err
 	}
 
-	w.dialer = *websocket.DefaultDialer
+	w.connMutex.Lock()
+	w.connSettings = connSettings
+	w.capabilities = capabilities
+	w.conn = nil
+	w.wsSender = nil
+	w.failedOverAt = time.Time{}
+	w.connMutex.Unlock()
 
-	if w.settings.TLSConfig != nil {
-		w.url.Scheme = "wss"
-	}
-	w.dialer.TLSClientConfig = w.settings.TLSConfig
+	w.backoff = newRetryBackoff(w.settings.Backoff, w.clock)
 
-	if w.settings.AuthorizationHeader != "" {
-		w.requestHeader = http.Header{}
-		w.requestHeader["Authorization"] = []string{w.settings.AuthorizationHeader}
+	if w.connSettings.transport == TransportHTTP {
+		w.httpSender = internal.NewHTTPSender(
+			w.logger, w.settings.Callbacks, w.sender, w.status, w.backoff, w.settings.HTTPPollingInterval,
+		)
+		w.httpSender.SetRequestSettings(w.connSettings.httpRequestSettings())
+		w.httpSender.SetFailover(w.failover)
+	} else {
+		w.dialer = *websocket.DefaultDialer
 	}
 
 	// Prepare the first status report.
 	w.sender.UpdateNextStatus(
 		func(statusReport *protobufs.StatusReport) {
 			statusReport.AgentDescription = w.settings.AgentDescription
+			statusReport.Capabilities = capabilities
+			if w.settings.LastEffectiveConfig != nil {
+				statusReport.EffectiveConfig = w.settings.LastEffectiveConfig
+			}
+			if w.settings.LastRemoteConfigHash != nil {
+				statusReport.RemoteConfigStatus = &protobufs.RemoteConfigStatus{
+					LastRemoteConfigHash: w.settings.LastRemoteConfigHash,
+				}
+			}
+			if w.settings.LastConnectionSettingsHash != nil {
+				statusReport.ConnectionStatuses = &protobufs.ConnectionStatuses{
+					LastConnectionSettingsHash: w.settings.LastConnectionSettingsHash,
+				}
+			}
 		},
 	)
 
@@ -122,6 +258,7 @@ func (w *client) Start(settings StartSettings) error {
 		},
 	)
 
+	w.status.SetConnecting(w.connSettings.url.String())
 	w.startConnectAndRun()
 
 	w.isStarted = true
@@ -130,6 +267,9 @@ func (w *client) Start(settings StartSettings) error {
 }
 
 func (w *client) Stop(ctx context.Context) error {
+	w.startStopMutex.Lock()
+	defer w.startStopMutex.Unlock()
+
 	if !w.isStarted {
 		return errCannotStopNotStarted
 	}
@@ -139,16 +279,30 @@ func (w *client) Stop(ctx context.Context) error {
 	w.isStoppingFlag = true
 	w.isStoppingMutex.Unlock()
 
-	cancelFunc()
-
-	// Close connection if any.
+	// Tell the server that we are going away if we are connected.
 	w.connMutex.RLock()
 	conn := w.conn
+	wsSender := w.wsSender
 	w.connMutex.RUnlock()
 
+	if wsSender != nil {
+		if err := wsSender.SendDisconnect(ctx); err != nil {
+			w.logger.Warn("Cannot send disconnect message", logging.Err(err))
+		}
+	}
+
+	cancelFunc()
+
+	// Close connection if any.
+
 	if conn != nil {
 		conn.Close()
 	}
+
+	// Cancel the in-flight callbacks and wait until they return.
+	if err := w.dispatcher.Stop(ctx); err != nil {
+		return err
+	}
 	// If we are not connected by this point we may still get connected because
 	// we are racing with tryConnectOnce() func. However, after tryConnectOnce we will
 	// check the isStopping flag in receiverLoop() and will exit receiverLoop, so we will
@@ -160,6 +314,39 @@ func (w *client) Stop(ctx context.Context) error {
 		return ctx.Err()
 	case <-w.stoppedSignal:
 	}
+	w.status.SetStopped()
+
+	if w.httpSender != nil {
+		// The sending is stopped, so we can tell the server that we are going away.
+		if err := w.httpSender.SendDisconnect(ctx, w.settings.InstanceUid); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.logger.Warn("Cannot send disconnect message", logging.Err(err))
+		}
+	}
+
+	// Close the connection that was established using newly offered settings
+	// but was never used, if any.
+	w.connMutex.Lock()
+	if w.pendingConn != nil {
+		w.pendingConn.Close()
+		w.pendingConn = nil
+	}
+	w.connMutex.Unlock()
+
+	// Wait until the most recent state is saved. The client is stopped even if
+	// the state cannot be saved.
+	var saveErr error
+	if w.stateSaver != nil {
+		saveErr = w.stateSaver.wait(ctx)
+	}
+
+	w.isStarted = false
+
+	if saveErr != nil {
+		return fmt.Errorf("cannot save state: %w", saveErr)
+	}
 	return nil
 }
 
@@ -183,102 +370,326 @@ func (w *client) SetEffectiveConfig(config *protobufs.EffectiveConfig) error {
 	return nil
 }
 
-type optionalDuration struct {
-	duration time.Duration
-	// true if duration field is defined.
-	defined bool
+func (w *client) Capabilities() Capabilities {
+	w.connMutex.RLock()
+	capabilities := w.capabilities
+	w.connMutex.RUnlock()
+
+	return Capabilities{
+		Agent:  capabilities,
+		Server: w.sender.ServerCapabilities(),
+	}
+}
+
+func (w *client) Status() types.Status {
+	return w.status.Status()
+}
+
+// dial establishes a WebSocket connection to the OpAMP Server using the
+// specified connection settings.
+func (w *client) dial(ctx context.Context, settings connectionSettings) (*websocket.Conn, *http.Response, error) {
+	target, netDial, proxy := settings.target()
+
+	dialer := w.dialer
+	dialer.TLSClientConfig = settings.tlsConfig
+	dialer.NetDialContext = netDial
+
+	// Connect through the proxy ourselves, so that the proxy headers are sent
+	// in the CONNECT request.
+	proxyURL, err := proxy.ProxyURL(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	dialer.Proxy = nil
+	if proxyURL != nil {
+		if netDial == nil {
+			var netDialer net.Dialer
+			netDial = netDialer.DialContext
+		}
+		dialer.NetDialContext = internal.ProxyDialContext(proxyURL, proxy.Header, netDial)
+	}
+
+	header, err := settings.auth.Header(ctx, settings.requestHeader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dialer.DialContext(ctx, target.String(), header)
+}
+
+// applyOpampSettings verifies the OpAMP connection settings offered by the server
+// by connecting to the server using the new settings. If the connection succeeds
+// the client switches to the new settings and to the new connection. If the
+// connection fails an error is returned and the client continues using the
+// current settings and connection.
+func (w *client) applyOpampSettings(ctx context.Context, offer *protobufs.ConnectionSettings) error {
+	w.connMutex.RLock()
+	curSettings := w.connSettings
+	w.connMutex.RUnlock()
+
+	newSettings, changed, err := curSettings.withOffer(offer)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		// Nothing to verify, we are already connected using these settings.
+		return nil
+	}
+
+	if err := w.verifySettings(ctx, newSettings); err != nil {
+		return fmt.Errorf("cannot connect using offered settings: %w", err)
+	}
+	return nil
+}
+
+// verifySettings connects to the server using the specified settings. If the
+// connection succeeds the client switches to the new settings and, when using
+// WebSocket transport, to the new connection.
+func (w *client) verifySettings(ctx context.Context, newSettings connectionSettings) error {
+	if newSettings.transport == TransportHTTP {
+		return w.verifyHTTPSettings(ctx, newSettings)
+	}
+
+	conn, _, err := w.dial(ctx, newSettings)
+	if err != nil {
+		return err
+	}
+
+	// Connected successfully, switch to the new settings and connection.
+	w.connMutex.Lock()
+	w.connSettings = newSettings
+	if w.pendingConn != nil {
+		w.pendingConn.Close()
+	}
+	w.pendingConn = conn
+	oldConn := w.conn
+	w.connMutex.Unlock()
+
+	// Close the current connection. This will make the client start over using
+	// the new connection.
+	if oldConn != nil {
+		oldConn.Close()
+	}
+
+	return nil
+}
+
+// verifyHTTPSettings verifies the new connection settings by making a request
+// to the server and switches to the new settings if the request succeeds.
+func (w *client) verifyHTTPSettings(ctx context.Context, newSettings connectionSettings) error {
+	err := w.httpSender.Probe(ctx, w.settings.InstanceUid, newSettings.httpRequestSettings())
+	if err != nil {
+		return err
+	}
+
+	w.connMutex.Lock()
+	w.connSettings = newSettings
+	w.connMutex.Unlock()
+
+	w.httpSender.SetRequestSettings(newSettings.httpRequestSettings())
+
+	return nil
+}
+
+// failover switches to the next server endpoint. Returns false if there is
+// only one endpoint.
+func (w *client) failover() bool {
+	w.connMutex.Lock()
+	if len(w.connSettings.endpoints) < 2 {
+		w.connMutex.Unlock()
+		return false
+	}
+	w.connSettings = w.connSettings.withEndpoint(w.connSettings.nextEndpoint())
+	w.failedOverAt = w.clock.Now()
+	newSettings := w.connSettings
+	w.connMutex.Unlock()
+
+	w.logger.Info("Failing over to the next server endpoint", logging.ServerURL(newSettings.url.String()))
+	if w.httpSender != nil {
+		w.httpSender.SetRequestSettings(newSettings.httpRequestSettings())
+	}
+	return true
+}
+
+// runFailback tries to go back to the primary endpoint when the client has
+// been using another endpoint for FailbackAfter. Returns when the ctx is
+// cancelled.
+func (w *client) runFailback(ctx context.Context) {
+	for {
+		w.connMutex.RLock()
+		wait := w.settings.FailbackAfter
+		if w.connSettings.endpoint != 0 {
+			wait -= w.clock.Now().Sub(w.failedOverAt)
+		}
+		w.connMutex.RUnlock()
+
+		timer := w.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		w.failback(ctx)
+	}
+}
+
+// failback switches to the primary endpoint if the client is connected to
+// another endpoint since at least FailbackAfter and can connect to the primary
+// endpoint. If it cannot, the client tries again after another FailbackAfter.
+func (w *client) failback(ctx context.Context) {
+	w.connMutex.RLock()
+	curSettings := w.connSettings
+	failedOverAt := w.failedOverAt
+	w.connMutex.RUnlock()
+
+	if curSettings.endpoint == 0 || w.clock.Now().Sub(failedOverAt) < w.settings.FailbackAfter {
+		return
+	}
+	if w.status.Status().State != types.StateConnected {
+		// When not connected the client goes through all endpoints anyway.
+		// Check again after another FailbackAfter.
+		w.connMutex.Lock()
+		w.failedOverAt = w.clock.Now()
+		w.connMutex.Unlock()
+		return
+	}
+
+	primary := curSettings.withEndpoint(0)
+	if err := w.verifySettings(ctx, primary); err != nil {
+		w.logger.Debug(
+			"Cannot fail back to the primary server endpoint",
+			logging.ServerURL(primary.url.String()), logging.Err(err),
+		)
+		w.connMutex.Lock()
+		w.failedOverAt = w.clock.Now()
+		w.connMutex.Unlock()
+		return
+	}
+	w.logger.Info("Failed back to the primary server endpoint", logging.ServerURL(primary.url.String()))
+}
+
+// usePendingConn makes the pending connection the current connection if there
+// is one. Returns true if the pending connection was used.
+func (w *client) usePendingConn() bool {
+	w.connMutex.Lock()
+	conn := w.pendingConn
+	w.pendingConn = nil
+	if conn != nil {
+		w.conn = conn
+	}
+	serverURL := w.connSettings.url.String()
+	w.connMutex.Unlock()
+
+	if conn == nil {
+		return false
+	}
+	w.status.SetConnected(serverURL)
+
+	if w.settings.Callbacks != nil {
+		w.settings.Callbacks.OnConnect(serverURL)
+	}
+	return true
 }
 
 // Try to connect once. Returns an error if connection fails and optional retryAfter
 // duration to indicate to the caller to retry after the specified time as instructed
 // by the server.
-func (w *client) tryConnectOnce(ctx context.Context) (err error, retryAfter optionalDuration) {
+func (w *client) tryConnectOnce(ctx context.Context) (err error, retryAfter internal.OptionalDuration) {
+	w.connMutex.RLock()
+	connSettings := w.connSettings
+	w.connMutex.RUnlock()
+
+	w.status.SetConnecting(connSettings.url.String())
+
 	var resp *http.Response
-	conn, resp, err := w.dialer.DialContext(ctx, w.url.String(), w.requestHeader)
+	conn, resp, err := w.dial(ctx, connSettings)
+	if resp != nil && resp.StatusCode == http.StatusUnauthorized && connSettings.auth.Refresh() {
+		// The token may have expired, retry immediately with a new one.
+		w.logger.Info(
+			"Server rejected the credentials, retrying with new ones",
+			logging.ServerURL(connSettings.url.String()),
+		)
+		conn, resp, err = w.dial(ctx, connSettings)
+	}
 	if err != nil {
+		w.status.SetError(err)
 		if w.settings.Callbacks != nil {
-			w.settings.Callbacks.OnConnectFailed(err)
+			w.settings.Callbacks.OnConnectFailed(connSettings.url.String(), err)
 		}
 		if resp != nil {
-			w.logger.Errorf("Server responded with status=%v", resp.Status)
-			duration := extractRetryAfterHeader(resp)
+			w.logger.Warn(
+				"Server responded with unexpected status",
+				logging.ServerURL(connSettings.url.String()), logging.String("status", resp.Status),
+			)
+			duration := internal.ExtractRetryAfterHeader(resp)
 			return err, duration
 		}
-		return err, optionalDuration{defined: false}
+		return err, internal.OptionalDuration{Defined: false}
 	}
 
 	// Successfully connected.
 	w.connMutex.Lock()
 	w.conn = conn
 	w.connMutex.Unlock()
+	w.status.SetConnected(connSettings.url.String())
+	w.logger.Info("Connected to the server", logging.ServerURL(connSettings.url.String()))
 	if w.settings.Callbacks != nil {
-		w.settings.Callbacks.OnConnect()
+		w.settings.Callbacks.OnConnect(connSettings.url.String())
 	}
 
-	return nil, optionalDuration{defined: false}
-}
-
-// Extract Retry-After response header if the status is 503 or 429. Returns
-// 0 duration if the header is not found or the status is different.
-func extractRetryAfterHeader(resp *http.Response) optionalDuration {
-	if resp.StatusCode == http.StatusServiceUnavailable ||
-		resp.StatusCode == http.StatusTooManyRequests {
-		retryAfter := strings.TrimSpace(resp.Header.Get(retryAfterHTTPHeader))
-		if retryAfter != "" {
-			retryIntervalSec, err := strconv.Atoi(retryAfter)
-			if err == nil {
-				retryInterval := time.Duration(retryIntervalSec) * time.Second
-				return optionalDuration{defined: true, duration: retryInterval}
-			}
-		}
-	}
-	return optionalDuration{defined: false}
+	return nil, internal.OptionalDuration{Defined: false}
 }
 
 // Continuously try until connected. Will return nil when successfully
 // connected. Will return error if it is cancelled via context.
-func (w *client) ensureConnected(ctx context.Context) error {
-	infiniteBackoff := backoff.NewExponentialBackOff()
-
-	// Make ticker run forever.
-	infiniteBackoff.MaxElapsedTime = 0
+// The first attempt is made immediately, unless we are reconnecting after the
+// connection was lost or retryAfter is defined (the server asked us to go away
+// and retry later). In that case the first attempt is delayed by the backoff
+// interval, so that the agents that lost the connection at the same time don't
+// reconnect all at once.
+func (w *client) ensureConnected(ctx context.Context, retryAfter internal.OptionalDuration) error {
+	if w.usePendingConn() {
+		// Already connected using newly offered connection settings.
+		w.backoff.Connected()
+		return nil
+	}
 
 	interval := time.Duration(0)
+	if w.reconnecting || retryAfter.Defined {
+		interval = w.backoff.NextInterval(retryAfter)
+	}
 
 	for {
-		timer := time.NewTimer(interval)
-		interval = infiniteBackoff.NextBackOff()
+		if interval > 0 {
+			w.status.SetBackoff(interval)
+		}
+		if err := w.backoff.Wait(ctx, interval); err != nil {
+			w.logger.Debug("Client is stopped, will not try anymore")
+			return err
+		}
 
-		select {
-		case <-timer.C:
-			{
-				if err, retryAfter := w.tryConnectOnce(ctx); err != nil {
-					if errors.Is(err, context.Canceled) {
-						w.logger.Debugf("Client is stopped, will not try anymore.")
-						return err
-					} else {
-						w.logger.Errorf("Connection failed (%v), will retry.", err)
-					}
-					// Retry again a bit later.
-
-					if retryAfter.defined && retryAfter.duration > interval {
-						// If the server suggested connecting later than our interval
-						// then honour server's request, otherwise wait at least
-						// as much as we calculated.
-						interval = retryAfter.duration
-					}
-
-					continue
-				}
-				// Connected successfully.
-				return nil
-			}
+		err, retryAfter := w.tryConnectOnce(ctx)
+		if err == nil {
+			// Connected successfully.
+			w.backoff.Connected()
+			return nil
+		}
+		if errors.Is(err, context.Canceled) {
+			w.logger.Debug("Client is stopped, will not try anymore")
+			return err
+		}
+		w.logger.Warn("Connection failed, will retry", logging.Err(err))
 
-		case <-ctx.Done():
-			w.logger.Debugf("Client is stopped, will not try anymore.")
-			timer.Stop()
-			return ctx.Err()
+		// Try the next endpoint, if any. When to retry as instructed by this
+		// server does not apply to the other servers.
+		if w.failover() {
+			retryAfter = internal.OptionalDuration{}
 		}
+
+		// Retry again a bit later.
+		interval = w.backoff.NextInterval(retryAfter)
 	}
 }
 
@@ -289,9 +700,6 @@ func (w *client) isStopping() bool {
 }
 
 func (w *client) startConnectAndRun() {
-	// Create a cancellable context.
-	runCtx, runCancel := context.WithCancel(context.Background())
-
 	w.isStoppingMutex.Lock()
 	defer w.isStoppingMutex.Unlock()
 
@@ -299,6 +707,9 @@ func (w *client) startConnectAndRun() {
 		// Stop() was called. Don't connect.
 		return
 	}
+
+	// Create a cancellable context.
+	runCtx, runCancel := context.WithCancel(context.Background())
 	w.runCancel = runCancel
 
 	go w.runUntilStopped(runCtx)
@@ -311,44 +722,89 @@ func (w *client) startConnectAndRun() {
 // If it encounters an error it closes the connection and returns.
 // Will stop and return if Stop() is called (ctx is cancelled, isStopping is set).
 func (w *client) runOneCycle(ctx context.Context) {
-	if err := w.ensureConnected(ctx); err != nil {
+	if err := w.ensureConnected(ctx, w.retryAfter); err != nil {
 		// Can't connect, so can't move forward. This currently happens when we
 		// are being stopped.
 		return
 	}
+	// When to retry as instructed by the server was used for this connection.
+	w.retryAfter = internal.OptionalDuration{}
 
 	if w.isStopping() {
 		w.conn.Close()
 		return
 	}
 
+	// The server may be a different one, its capabilities are learned from
+	// its responses. Until then nothing is removed from the messages.
+	w.sender.SetServerCapabilities(protobufs.ServerCapabilities_UnspecifiedServerCapability)
+
 	// Create a cancellable context for background processors.
 	procCtx, procCancel := context.WithCancel(ctx)
 
-	// Connected successfully. Start the sender. This will also send the first
-	// status report.
-	if err := w.sender.Start(procCtx, w.settings.InstanceUid, w.conn); err != nil {
-		w.logger.Errorf("Failed to send first status report: %v", err)
+	// Connected successfully. Start the sender. This will also send the full
+	// state of the agent, the server may know nothing about it if we were
+	// connected to a different server or if the server restarted.
+	sender := internal.NewWSSender(w.logger, w.sender, w.status)
+	if err := sender.Start(procCtx, w.settings.InstanceUid, w.conn); err != nil {
+		w.logger.Warn("Failed to send first status report", logging.Err(err))
 		// We could not send the report, the only thing we can do is start over.
+		w.retryAfter = internal.OptionalDuration{}
+		procCancel()
+		w.backoff.Disconnected()
+		w.reconnecting = true
 		w.conn.Close()
+		sender.WaitToStop()
 		return
 	}
 
+	w.connMutex.Lock()
+	w.wsSender = sender
+	w.connMutex.Unlock()
+
+	// Begin pinging the server to detect if the connection is lost.
+	keepalive := sharedinternal.StartWSKeepalive(w.conn, w.settings.PingInterval, w.settings.PongTimeout)
+
 	// First status report sent. Now loop to receive and process messages.
-	r := internal.NewReceiver(w.logger, w.settings.Callbacks, w.conn, w.sender)
-	r.ReceiverLoop(ctx)
+	r := internal.NewWSReceiver(w.logger, w.conn, keepalive, w.newReceiver(), w.status)
+	w.retryAfter = r.ReceiverLoop(ctx)
+	if w.retryAfter.Defined && w.failover() {
+		// The server is unavailable, try the next endpoint. When to retry as
+		// instructed by this server does not apply to the other servers.
+		w.retryAfter = internal.OptionalDuration{}
+	}
+
+	w.connMutex.Lock()
+	w.wsSender = nil
+	w.connMutex.Unlock()
 
 	// Stop the background processors.
 	procCancel()
+	keepalive.Stop()
+
+	// The connection is lost, we will reconnect.
+	w.backoff.Disconnected()
+	w.reconnecting = true
+	w.connMutex.RLock()
+	serverURL := w.connSettings.url.String()
+	w.connMutex.RUnlock()
+	w.logger.Info("Disconnected from the server", logging.ServerURL(serverURL))
+	w.status.SetConnecting(serverURL)
 
 	// If we exited receiverLoop it means there is a connection error, we cannot
-	// read messages anymore. We need to start over.
+	// read messages anymore, or the server asked us to go away. We need to start over.
 
 	// Close the connection to unblock the Sender as well.
 	w.conn.Close()
 
 	// Wait for Sender to stop.
-	w.sender.WaitToStop()
+	sender.WaitToStop()
+}
+
+func (w *client) newReceiver() *internal.Receiver {
+	return internal.NewReceiver(
+		w.logger, w.settings.Callbacks, w.sender, w.dispatcher, w.applyOpampSettings,
+	)
 }
 
 func (w *client) runUntilStopped(ctx context.Context) {
@@ -356,9 +812,29 @@ func (w *client) runUntilStopped(ctx context.Context) {
 	defer func() {
 		// We only return from runUntilStopped when we are instructed to stop.
 		// When returning signal that we stopped.
-		w.stoppedSignal <- struct{}{}
+		close(w.stoppedSignal)
 	}()
 
+	w.connMutex.RLock()
+	failoverEnabled := len(w.connSettings.endpoints) > 1
+	w.connMutex.RUnlock()
+	if failoverEnabled && w.settings.FailbackAfter > 0 {
+		// Go back to the primary endpoint after failing over. Wait until it
+		// is done before signalling that we stopped.
+		failbackStopped := make(chan struct{})
+		go func() {
+			w.runFailback(ctx)
+			close(failbackStopped)
+		}()
+		defer func() { <-failbackStopped }()
+	}
+
+	if w.httpSender != nil {
+		// Send messages and poll the server until we are stopped.
+		w.httpSender.Run(ctx, w.settings.InstanceUid, w.newReceiver())
+		return
+	}
+
 	// Iterates until we detect that the client is stopping.
 	for {
 		if w.isStopping() {
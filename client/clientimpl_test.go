@@ -1,9 +1,27 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -17,6 +35,7 @@ import (
 	"github.com/open-telemetry/opamp-go/client/internal"
 	"github.com/open-telemetry/opamp-go/client/types"
 	"github.com/open-telemetry/opamp-go/internal/testhelpers"
+	"github.com/open-telemetry/opamp-go/logging"
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
@@ -34,16 +53,22 @@ func eventually(t *testing.T, f func() bool) {
 	assert.Eventually(t, f, 5*time.Second, 10*time.Millisecond)
 }
 
-func prepareClient(settings StartSettings) *client {
-	// Autogenerate instance id.
+func newInstanceUid() string {
 	entropy := ulid.Monotonic(rand.New(rand.NewSource(99)), 0)
-	settings.InstanceUid = ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
+	return ulid.MustNew(ulid.Timestamp(time.Now()), entropy).String()
+}
+
+func prepareClient(settings *StartSettings) *client {
+	// Autogenerate instance id.
+	if settings.InstanceUid == "" {
+		settings.InstanceUid = newInstanceUid()
+	}
 
 	return New(nil)
 }
 
 func startClient(t *testing.T, settings StartSettings) *client {
-	client := prepareClient(settings)
+	client := prepareClient(&settings)
 	err := client.Start(settings)
 	assert.NoError(t, err)
 	return client
@@ -53,6 +78,7 @@ func startClient(t *testing.T, settings StartSettings) *client {
 func createNoServerSettings() StartSettings {
 	return StartSettings{
 		OpAMPServerURL:   "ws://" + testhelpers.GetAvailableLocalAddress(),
+		InstanceUid:      newInstanceUid(),
 		AgentDescription: &protobufs.AgentDescription{},
 	}
 }
@@ -68,7 +94,7 @@ func TestOnConnectFail(t *testing.T) {
 	var connectErr atomic.Value
 	settings := createNoServerSettings()
 	settings.Callbacks = CallbacksStruct{
-		OnConnectFailedFunc: func(err error) {
+		OnConnectFailedFunc: func(serverURL string, err error) {
 			connectErr.Store(err)
 		},
 	}
@@ -118,7 +144,7 @@ func TestConnectWithServer(t *testing.T) {
 	var connected int64
 	settings := StartSettings{
 		Callbacks: CallbacksStruct{
-			OnConnectFunc: func() {
+			OnConnectFunc: func(serverURL string) {
 				atomic.StoreInt64(&connected, 1)
 			},
 		},
@@ -146,7 +172,7 @@ func TestConnectWithServer503(t *testing.T) {
 		atomic.StoreInt64(&connectionAttempts, 1)
 
 		// Always respond with an error to the client.
-		w.Header().Set(retryAfterHTTPHeader, "30")
+		w.Header().Set(internal.RetryAfterHTTPHeader, "30")
 		w.WriteHeader(http.StatusServiceUnavailable)
 	}
 
@@ -155,11 +181,11 @@ func TestConnectWithServer503(t *testing.T) {
 	var connectErr atomic.Value
 	settings := StartSettings{
 		Callbacks: CallbacksStruct{
-			OnConnectFunc: func() {
+			OnConnectFunc: func(serverURL string) {
 				atomic.StoreInt64(&clientConnected, 1)
 				assert.Fail(t, "Client should not be able to connect")
 			},
-			OnConnectFailedFunc: func(err error) {
+			OnConnectFailedFunc: func(serverURL string, err error) {
 				connectErr.Store(err)
 			},
 		},
@@ -218,10 +244,10 @@ func TestDisconnectByServer(t *testing.T) {
 	var connectErr atomic.Value
 	settings := StartSettings{
 		Callbacks: CallbacksStruct{
-			OnConnectFunc: func() {
+			OnConnectFunc: func(serverURL string) {
 				atomic.StoreInt64(&connected, 1)
 			},
-			OnConnectFailedFunc: func(err error) {
+			OnConnectFailedFunc: func(serverURL string, err error) {
 				connectErr.Store(err)
 			},
 		},
@@ -267,7 +293,7 @@ func TestFirstStatusReport(t *testing.T) {
 	var connected, remoteConfigReceived int64
 	settings := StartSettings{
 		Callbacks: CallbacksStruct{
-			OnConnectFunc: func() {
+			OnConnectFunc: func(serverURL string) {
 				atomic.AddInt64(&connected, 1)
 			},
 			OnRemoteConfigFunc: func(
@@ -318,7 +344,7 @@ func TestSetEffectiveConfig(t *testing.T) {
 	settings := StartSettings{}
 	settings.OpAMPServerURL = "ws://" + srv.Endpoint
 	settings.AgentDescription = &protobufs.AgentDescription{}
-	client := prepareClient(settings)
+	client := prepareClient(&settings)
 
 	sendConfig := &protobufs.EffectiveConfig{
 		ConfigMap: &protobufs.AgentConfigMap{
@@ -384,7 +410,7 @@ func TestSetAgentAttributes(t *testing.T) {
 		OpAMPServerURL:   "ws://" + srv.Endpoint,
 		AgentDescription: &protobufs.AgentDescription{},
 	}
-	client := prepareClient(settings)
+	client := prepareClient(&settings)
 
 	settings.AgentDescription = &protobufs.AgentDescription{
 		IdentifyingAttributes: []*protobufs.KeyValue{
@@ -443,12 +469,21 @@ func TestConnectionSettings(t *testing.T) {
 	otherSettings := &protobufs.ConnectionSettings{DestinationEndpoint: "http://other.com"}
 
 	var rcvStatus int64
+	var rcvConnStatuses atomic.Value
 	// Start a server.
 	srv := internal.StartMockServer(t)
 	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
 		if statusReport := msg.GetStatusReport(); statusReport != nil {
 			atomic.AddInt64(&rcvStatus, 1)
 
+			if statusReport.ConnectionStatuses != nil {
+				rcvConnStatuses.Store(statusReport.ConnectionStatuses)
+				if bytes.Equal(statusReport.ConnectionStatuses.LastConnectionSettingsHash, hash) {
+					// The agent already has these settings.
+					return nil
+				}
+			}
+
 			return &protobufs.ServerToAgent{
 				ConnectionSettings: &protobufs.ConnectionSettingsOffers{
 					Hash:       hash,
@@ -508,14 +543,518 @@ func TestConnectionSettings(t *testing.T) {
 		AgentDescription: &protobufs.AgentDescription{},
 	}
 	settings.OpAMPServerURL = "ws://" + srv.Endpoint
-	client := prepareClient(settings)
+	client := prepareClient(&settings)
 
 	assert.NoError(t, client.Start(settings))
 
 	eventually(t, func() bool { return atomic.LoadInt64(&gotOpampSettings) == 1 })
 	eventually(t, func() bool { return atomic.LoadInt64(&gotOwnSettings) == 3 })
 	eventually(t, func() bool { return atomic.LoadInt64(&gotOtherSettings) == 1 })
-	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+
+	// The client must report the statuses of the offered settings.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 2 })
+	statuses := rcvConnStatuses.Load().(*protobufs.ConnectionStatuses)
+	assert.EqualValues(t, hash, statuses.LastConnectionSettingsHash)
+	accepted := &protobufs.ConnectionStatus{Status: protobufs.ConnectionStatus_Accepted}
+	assert.True(t, proto.Equal(accepted, statuses.Opamp))
+	assert.True(t, proto.Equal(accepted, statuses.OwnMetrics))
+	assert.True(t, proto.Equal(accepted, statuses.OwnTraces))
+	assert.True(t, proto.Equal(accepted, statuses.OwnLogs))
+	assert.True(t, proto.Equal(accepted, statuses.OtherConnections["other"]))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+// inMemAddonStore is an AddonStateProvider that keeps the addons in memory.
+type inMemAddonStore struct {
+	mux           sync.Mutex
+	allAddonsHash []byte
+	addonHashes   map[string][]byte
+	fileContents  map[string][]byte
+	fileHashes    map[string][]byte
+}
+
+var _ types.AddonStateProvider = (*inMemAddonStore)(nil)
+
+func newInMemAddonStore() *inMemAddonStore {
+	return &inMemAddonStore{
+		addonHashes:  map[string][]byte{},
+		fileContents: map[string][]byte{},
+		fileHashes:   map[string][]byte{},
+	}
+}
+
+func (s *inMemAddonStore) AllAddonsHash() ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.allAddonsHash, nil
+}
+
+func (s *inMemAddonStore) Addons() ([]string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var names []string
+	for name := range s.addonHashes {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (s *inMemAddonStore) AddonHash(addonName string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.addonHashes[addonName], nil
+}
+
+func (s *inMemAddonStore) CreateAddon(addonName string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, exists := s.addonHashes[addonName]; exists {
+		return errors.New("addon already exists")
+	}
+	s.addonHashes[addonName] = nil
+	return nil
+}
+
+func (s *inMemAddonStore) FileContentHash(addonName string) ([]byte, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.fileHashes[addonName], nil
+}
+
+func (s *inMemAddonStore) UpdateContent(
+	ctx context.Context, addonName string, data io.Reader, contentHash []byte,
+) error {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.fileContents[addonName] = content
+	s.fileHashes[addonName] = contentHash
+	return nil
+}
+
+func (s *inMemAddonStore) SetAddonHash(addonName string, hash []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.addonHashes[addonName] = hash
+	return nil
+}
+
+func (s *inMemAddonStore) DeleteAddon(addonName string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.addonHashes, addonName)
+	delete(s.fileContents, addonName)
+	delete(s.fileHashes, addonName)
+	return nil
+}
+
+func (s *inMemAddonStore) SetAllAddonsHash(hash []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.allAddonsHash = hash
+	return nil
+}
+
+// startFileServer starts an HTTP server that serves the specified files
+// and returns the base URL of the server.
+func startFileServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func contentHash(content []byte) []byte {
+	hash := sha256.Sum256(content)
+	return hash[:]
+}
+
+func TestAddonsAvailable(t *testing.T) {
+	addonContent := []byte("addon content")
+	badContent := []byte("corrupted content")
+	fileSrv := startFileServer(t, map[string][]byte{
+		"/addon1": addonContent,
+		"/addon2": badContent,
+	})
+
+	addonsAvailable := &protobufs.AddonsAvailable{
+		Addons: map[string]*protobufs.AddonAvailable{
+			"addon1": {
+				File: &protobufs.DownloadableFile{
+					DownloadUrl: fileSrv.URL + "/addon1",
+					ContentHash: contentHash(addonContent),
+				},
+				Hash: []byte{1},
+			},
+			"addon2": {
+				File: &protobufs.DownloadableFile{
+					DownloadUrl: fileSrv.URL + "/addon2",
+					ContentHash: contentHash(addonContent),
+				},
+				Hash: []byte{2},
+			},
+		},
+		AllAddonsHash: []byte{1, 2},
+	}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var offered int64
+	var rcvStatuses atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.AddonStatuses != nil {
+			rcvStatuses.Store(msg.AddonStatuses)
+		}
+		if atomic.AddInt64(&offered, 1) == 1 {
+			// Offer the addons in response to the first message.
+			return &protobufs.ServerToAgent{
+				InstanceUid:     msg.InstanceUid,
+				AddonsAvailable: addonsAvailable,
+			}
+		}
+		return nil
+	}
+
+	localState := newInMemAddonStore()
+	// Local addon that is not offered by the server and must be deleted.
+	localState.addonHashes["oldaddon"] = []byte{3}
+
+	var syncErr atomic.Value
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnAddonsAvailableFunc: func(
+				ctx context.Context, addons *protobufs.AddonsAvailable, syncer types.AddonSyncer,
+			) error {
+				assert.True(t, proto.Equal(addonsAvailable, addons))
+				err := syncer.Sync(ctx, localState)
+				syncErr.Store(err)
+				return err
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// Wait until the syncing is done.
+	eventually(t, func() bool { return syncErr.Load() != nil })
+
+	// addon2 content does not match the hash so the sync must fail.
+	assert.Error(t, syncErr.Load().(error))
+
+	localState.mux.Lock()
+	assert.EqualValues(t, addonContent, localState.fileContents["addon1"])
+	assert.EqualValues(t, []byte{1}, localState.addonHashes["addon1"])
+	assert.Nil(t, localState.fileContents["addon2"])
+	_, exists := localState.addonHashes["oldaddon"]
+	assert.False(t, exists)
+	// The sync was not fully successful so the aggregate hash must not be updated.
+	assert.Nil(t, localState.allAddonsHash)
+	localState.mux.Unlock()
+
+	// Verify the statuses reported to the server.
+	eventually(t, func() bool {
+		statuses, ok := rcvStatuses.Load().(*protobufs.AgentAddonStatuses)
+		if !ok {
+			return false
+		}
+		return statuses.Addons["addon1"].Status == protobufs.AgentAddonStatus_Installed &&
+			statuses.Addons["addon2"].Status == protobufs.AgentAddonStatus_InstallFailed
+	})
+	// The reported error must name the hash algorithm.
+	statuses := rcvStatuses.Load().(*protobufs.AgentAddonStatuses)
+	assert.Contains(t, statuses.Addons["addon2"].ErrorMessage, "SHA-256")
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestAddonsAvailableAllSynced(t *testing.T) {
+	addonContent := []byte("addon content")
+	fileSrv := startFileServer(t, map[string][]byte{"/addon1": addonContent})
+
+	addonsAvailable := &protobufs.AddonsAvailable{
+		Addons: map[string]*protobufs.AddonAvailable{
+			"addon1": {
+				File: &protobufs.DownloadableFile{
+					DownloadUrl: fileSrv.URL + "/addon1",
+					ContentHash: contentHash(addonContent),
+				},
+				Hash: []byte{1},
+			},
+		},
+		AllAddonsHash: []byte{1},
+	}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var offered int64
+	var rcvAllAddonsHash atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.AddonStatuses != nil {
+			rcvAllAddonsHash.Store(msg.AddonStatuses.ServerProvidedAllAddonsHash)
+		}
+		if atomic.AddInt64(&offered, 1) == 1 {
+			return &protobufs.ServerToAgent{
+				InstanceUid:     msg.InstanceUid,
+				AddonsAvailable: addonsAvailable,
+			}
+		}
+		return nil
+	}
+
+	localState := newInMemAddonStore()
+	var syncDone int64
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnAddonsAvailableFunc: func(
+				ctx context.Context, addons *protobufs.AddonsAvailable, syncer types.AddonSyncer,
+			) error {
+				err := syncer.Sync(ctx, localState)
+				assert.NoError(t, err)
+				atomic.StoreInt64(&syncDone, 1)
+				return err
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return atomic.LoadInt64(&syncDone) == 1 })
+
+	localState.mux.Lock()
+	assert.EqualValues(t, addonContent, localState.fileContents["addon1"])
+	assert.EqualValues(t, addonsAvailable.AllAddonsHash, localState.allAddonsHash)
+	localState.mux.Unlock()
+
+	// The server must be told the new aggregate hash.
+	eventually(t, func() bool {
+		hash, ok := rcvAllAddonsHash.Load().([]byte)
+		return ok && bytes.Equal(hash, addonsAvailable.AllAddonsHash)
+	})
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+// inMemPackageStore is an AgentPackageStateProvider that keeps the package in memory.
+type inMemPackageStore struct {
+	mux         sync.Mutex
+	version     string
+	content     []byte
+	contentHash []byte
+	updateErr   error
+}
+
+var _ types.AgentPackageStateProvider = (*inMemPackageStore)(nil)
+
+func (s *inMemPackageStore) PackageInfo() (version string, contentHash []byte, err error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.version, s.contentHash, nil
+}
+
+func (s *inMemPackageStore) UpdateContent(
+	ctx context.Context, data io.Reader, contentHash []byte, version string,
+) error {
+	if s.updateErr != nil {
+		return s.updateErr
+	}
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.content = content
+	s.contentHash = contentHash
+	s.version = version
+	return nil
+}
+
+func syncAgentPackage(
+	t *testing.T, localState *inMemPackageStore, packageAvailable *protobufs.AgentPackageAvailable,
+) (syncErr error, installStatus *protobufs.AgentInstallStatus) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var offered int64
+	var rcvStatus atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.AgentInstallStatus != nil {
+			rcvStatus.Store(msg.AgentInstallStatus)
+		}
+		if atomic.AddInt64(&offered, 1) == 1 {
+			return &protobufs.ServerToAgent{
+				InstanceUid:           msg.InstanceUid,
+				AgentPackageAvailable: packageAvailable,
+			}
+		}
+		return nil
+	}
+
+	var syncDone int64
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnAgentPackageAvailableFunc: func(
+				ctx context.Context,
+				available *protobufs.AgentPackageAvailable,
+				syncer types.AgentPackageSyncer,
+			) error {
+				assert.True(t, proto.Equal(packageAvailable, available))
+				syncErr = syncer.Sync(ctx, localState)
+				atomic.StoreInt64(&syncDone, 1)
+				return syncErr
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return atomic.LoadInt64(&syncDone) == 1 })
+
+	// Wait for the final status to be reported.
+	eventually(t, func() bool {
+		status, ok := rcvStatus.Load().(*protobufs.AgentInstallStatus)
+		return ok && status.Status != protobufs.AgentInstallStatus_Installing
+	})
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+
+	return syncErr, rcvStatus.Load().(*protobufs.AgentInstallStatus)
+}
+
+func TestAgentPackageAvailable(t *testing.T) {
+	packageContent := []byte("package content")
+	fileSrv := startFileServer(t, map[string][]byte{"/agent": packageContent})
+
+	packageAvailable := &protobufs.AgentPackageAvailable{
+		Version: "1.2.3",
+		File: &protobufs.DownloadableFile{
+			DownloadUrl: fileSrv.URL + "/agent",
+			ContentHash: contentHash(packageContent),
+		},
+	}
+
+	localState := &inMemPackageStore{version: "1.0.0"}
+	syncErr, status := syncAgentPackage(t, localState, packageAvailable)
+
+	assert.NoError(t, syncErr)
+	assert.EqualValues(t, protobufs.AgentInstallStatus_Installed, status.Status)
+	assert.EqualValues(t, "1.2.3", status.ServerOfferedVersion)
+	assert.EqualValues(t, packageAvailable.File.ContentHash, status.ServerOfferedHash)
+
+	assert.EqualValues(t, "1.2.3", localState.version)
+	assert.EqualValues(t, packageContent, localState.content)
+}
+
+func TestAgentPackageAvailableFailures(t *testing.T) {
+	packageContent := []byte("package content")
+	fileSrv := startFileServer(t, map[string][]byte{"/agent": packageContent})
+
+	tests := []struct {
+		name        string
+		contentHash []byte
+		updateErr   error
+		status      protobufs.AgentInstallStatus_Status
+	}{
+		{
+			name:        "hash mismatch",
+			contentHash: contentHash([]byte("other content")),
+			status:      protobufs.AgentInstallStatus_InstallFailed,
+		},
+		{
+			name:        "no permission",
+			contentHash: contentHash(packageContent),
+			updateErr:   fmt.Errorf("cannot write: %w", os.ErrPermission),
+			status:      protobufs.AgentInstallStatus_InstallNoPermission,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			packageAvailable := &protobufs.AgentPackageAvailable{
+				Version: "1.2.3",
+				File: &protobufs.DownloadableFile{
+					DownloadUrl: fileSrv.URL + "/agent",
+					ContentHash: test.contentHash,
+				},
+			}
+
+			localState := &inMemPackageStore{version: "1.0.0", updateErr: test.updateErr}
+			syncErr, status := syncAgentPackage(t, localState, packageAvailable)
+
+			assert.Error(t, syncErr)
+			assert.EqualValues(t, test.status, status.Status)
+			assert.NotEmpty(t, status.ErrorMessage)
+			assert.EqualValues(t, "1.0.0", localState.version)
+		})
+	}
+}
+
+func TestServerErrorResponse(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ErrorResponse: &protobufs.ServerErrorResponse{
+				Type:         protobufs.ServerErrorResponse_Unknown,
+				ErrorMessage: "something went wrong",
+			},
+		}
+	}
+
+	// Start a client.
+	var rcvErr atomic.Value
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnErrorFunc: func(err *protobufs.ServerErrorResponse) {
+				rcvErr.Store(err)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return rcvErr.Load() != nil })
+	assert.EqualValues(t, "something went wrong", rcvErr.Load().(*protobufs.ServerErrorResponse).ErrorMessage)
 
 	// Shutdown the server.
 	srv.Close()
@@ -524,3 +1063,3097 @@ func TestConnectionSettings(t *testing.T) {
 	err := client.Stop(context.Background())
 	assert.NoError(t, err)
 }
+
+func TestServerUnavailable(t *testing.T) {
+	const retryAfter = 200 * time.Millisecond
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var connectTimes []time.Time
+	var connectTimesMux sync.Mutex
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		connectTimesMux.Lock()
+		connectTimes = append(connectTimes, time.Now())
+		connectTimesMux.Unlock()
+	}
+	var responded int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if atomic.AddInt64(&responded, 1) == 1 {
+			// Tell the first connection to go away.
+			return &protobufs.ServerToAgent{
+				InstanceUid: msg.InstanceUid,
+				ErrorResponse: &protobufs.ServerErrorResponse{
+					Type: protobufs.ServerErrorResponse_Unavailable,
+					Details: &protobufs.ServerErrorResponse_RetryInfo{
+						RetryInfo: &protobufs.RetryInfo{
+							RetryAfterNanoseconds: uint64(retryAfter),
+						},
+					},
+				},
+			}
+		}
+		return nil
+	}
+
+	// Start a client.
+	var rcvErr atomic.Value
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnErrorFunc: func(err *protobufs.ServerErrorResponse) {
+				rcvErr.Store(err)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The client must report the error and reconnect.
+	eventually(t, func() bool { return rcvErr.Load() != nil })
+	eventually(t, func() bool {
+		connectTimesMux.Lock()
+		defer connectTimesMux.Unlock()
+		return len(connectTimes) == 2
+	})
+
+	// The reconnection must honour the retry interval requested by the server.
+	connectTimesMux.Lock()
+	assert.GreaterOrEqual(t, connectTimes[1].Sub(connectTimes[0]), retryAfter)
+	connectTimesMux.Unlock()
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestServerBadRequestResendsFullState(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvDescriptions int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.GetStatusReport().GetAgentDescription() != nil {
+			atomic.AddInt64(&rcvDescriptions, 1)
+		}
+		// Respond with an error to all messages.
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ErrorResponse: &protobufs.ServerErrorResponse{
+				Type: protobufs.ServerErrorResponse_BadRequest,
+			},
+		}
+	}
+
+	// Start a client.
+	var rcvErrors int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnErrorFunc: func(err *protobufs.ServerErrorResponse) {
+				atomic.AddInt64(&rcvErrors, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The client must resend the full state, including the agent description,
+	// but only once.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvDescriptions) == 2 })
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvErrors) == 2 })
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt64(&rcvDescriptions))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestOpampConnectionSettingsAccepted(t *testing.T) {
+	// Start the server that the client will be told to switch to.
+	newSrv := internal.StartMockServer(t)
+	var newSrvConnected int64
+	newSrv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		assert.EqualValues(t, "Bearer new", r.Header.Get("Authorization"))
+		atomic.AddInt64(&newSrvConnected, 1)
+	}
+	var newSrvRcvStatuses atomic.Value
+	newSrv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if statuses := msg.GetStatusReport().GetConnectionStatuses(); statuses != nil {
+			newSrvRcvStatuses.Store(statuses)
+		}
+		return nil
+	}
+
+	opampSettings := &protobufs.ConnectionSettings{
+		DestinationEndpoint: "ws://" + newSrv.Endpoint,
+		Headers: &protobufs.Headers{
+			Headers: []*protobufs.Header{{Key: "Authorization", Value: "Bearer new"}},
+		},
+		Flags: protobufs.ConnectionSettings_DestinationEndpointSet,
+	}
+
+	// Start the server that offers the new settings.
+	srv := internal.StartMockServer(t)
+	var srvConnected int64
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		atomic.AddInt64(&srvConnected, 1)
+	}
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ConnectionSettings: &protobufs.ConnectionSettingsOffers{
+				Hash:  []byte{1},
+				Opamp: opampSettings,
+			},
+		}
+	}
+
+	// Start a client.
+	var accepted atomic.Value
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnOpampConnectionSettingsAcceptedFunc: func(settings *protobufs.ConnectionSettings) {
+				// The client must already be connected using the new settings.
+				assert.EqualValues(t, 1, atomic.LoadInt64(&newSrvConnected))
+				accepted.Store(settings)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return accepted.Load() != nil })
+	assert.True(t, proto.Equal(opampSettings, accepted.Load().(*protobufs.ConnectionSettings)))
+
+	// The client must continue working with the new server. The status that
+	// could not be sent over the old connection must be sent to the new server.
+	eventually(t, func() bool { return newSrvRcvStatuses.Load() != nil })
+	statuses := newSrvRcvStatuses.Load().(*protobufs.ConnectionStatuses)
+	assert.EqualValues(t, protobufs.ConnectionStatus_Accepted, statuses.GetOpamp().GetStatus())
+	assert.EqualValues(t, 1, atomic.LoadInt64(&srvConnected))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&newSrvConnected))
+
+	// Shutdown the servers.
+	srv.Close()
+	newSrv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestOpampConnectionSettingsRejected(t *testing.T) {
+	opampSettings := &protobufs.ConnectionSettings{
+		// Point to a non-existing server.
+		DestinationEndpoint: "ws://" + testhelpers.GetAvailableLocalAddress(),
+		Flags:               protobufs.ConnectionSettings_DestinationEndpointSet,
+	}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var srvConnected int64
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		atomic.AddInt64(&srvConnected, 1)
+	}
+	var rcvStatus int64
+	var rcvConnStatuses atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if statuses := msg.GetStatusReport().GetConnectionStatuses(); statuses != nil {
+			rcvConnStatuses.Store(statuses)
+		}
+		if atomic.AddInt64(&rcvStatus, 1) == 1 {
+			return &protobufs.ServerToAgent{
+				InstanceUid: msg.InstanceUid,
+				ConnectionSettings: &protobufs.ConnectionSettingsOffers{
+					Hash:  []byte{1},
+					Opamp: opampSettings,
+				},
+			}
+		}
+		return nil
+	}
+
+	// Start a client.
+	var offered, accepted int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnOpampConnectionSettingsFunc: func(
+				ctx context.Context, settings *protobufs.ConnectionSettings,
+			) error {
+				atomic.AddInt64(&offered, 1)
+				return nil
+			},
+			OnOpampConnectionSettingsAcceptedFunc: func(settings *protobufs.ConnectionSettings) {
+				atomic.AddInt64(&accepted, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return atomic.LoadInt64(&offered) == 1 })
+
+	// The client must report the rejection using the old connection.
+	eventually(t, func() bool { return rcvConnStatuses.Load() != nil })
+	statuses := rcvConnStatuses.Load().(*protobufs.ConnectionStatuses)
+	assert.EqualValues(t, []byte{1}, statuses.LastConnectionSettingsHash)
+	assert.EqualValues(t, protobufs.ConnectionStatus_Rejected, statuses.Opamp.Status)
+	assert.NotEmpty(t, statuses.Opamp.ErrorMessage)
+	assert.EqualValues(t, 0, atomic.LoadInt64(&accepted))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&srvConnected))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestOpampConnectionSettingsUnsupportedDestination(t *testing.T) {
+	// Start a server that offers to connect to itself using an unsupported
+	// scheme.
+	srv := internal.StartMockServer(t)
+	var srvConnected int64
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		atomic.AddInt64(&srvConnected, 1)
+	}
+	var rcvConnStatuses atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if statuses := msg.GetStatusReport().GetConnectionStatuses(); statuses != nil {
+			rcvConnStatuses.Store(statuses)
+			return nil
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ConnectionSettings: &protobufs.ConnectionSettingsOffers{
+				Hash: []byte{1},
+				Opamp: &protobufs.ConnectionSettings{
+					DestinationEndpoint: "ftp://" + srv.Endpoint,
+					Flags:               protobufs.ConnectionSettings_DestinationEndpointSet,
+				},
+			},
+		}
+	}
+
+	// Start a client.
+	var accepted int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnOpampConnectionSettingsAcceptedFunc: func(settings *protobufs.ConnectionSettings) {
+				atomic.AddInt64(&accepted, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The offer must be rejected without connecting to the destination.
+	eventually(t, func() bool { return rcvConnStatuses.Load() != nil })
+	statuses := rcvConnStatuses.Load().(*protobufs.ConnectionStatuses)
+	assert.EqualValues(t, protobufs.ConnectionStatus_Rejected, statuses.Opamp.Status)
+	assert.Contains(t, statuses.Opamp.ErrorMessage, "unsupported scheme")
+	assert.EqualValues(t, 0, atomic.LoadInt64(&accepted))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&srvConnected))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestConnectionSettingsWithOfferChecksScheme(t *testing.T) {
+	tests := []struct {
+		name        string
+		tlsConfig   *tls.Config
+		destination string
+		valid       bool
+	}{
+		{name: "ws", destination: "ws://127.0.0.1:4320", valid: true},
+		{name: "http", destination: "http://127.0.0.1:4320", valid: true},
+		{name: "unix", destination: "unix:///run/opamp.sock", valid: true},
+		{name: "wss with tls", tlsConfig: &tls.Config{}, destination: "wss://127.0.0.1:4320", valid: true},
+		{name: "ws with tls", tlsConfig: &tls.Config{}, destination: "ws://127.0.0.1:4320"},
+		{name: "ftp", destination: "ftp://127.0.0.1:4320"},
+		{name: "no scheme", destination: "127.0.0.1:4320"},
+		{name: "unix with host", destination: "unix://localhost/run/opamp.sock"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := newConnectionSettings(&StartSettings{
+				OpAMPServerURL: "wss://127.0.0.1:4321",
+				TLSConfig:      test.tlsConfig,
+			})
+			assert.NoError(t, err)
+
+			newSettings, changed, err := s.withOffer(&protobufs.ConnectionSettings{
+				DestinationEndpoint: test.destination,
+				Flags:               protobufs.ConnectionSettings_DestinationEndpointSet,
+			})
+			if !test.valid {
+				assert.Error(t, err)
+				assert.False(t, changed)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, changed)
+			assert.NotEqualValues(t, "wss://127.0.0.1:4321", newSettings.url.String())
+		})
+	}
+}
+
+func TestHTTPTransport(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvDescription atomic.Value
+	var rcvMessages int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		atomic.AddInt64(&rcvMessages, 1)
+		if descr := msg.GetStatusReport().GetAgentDescription(); descr != nil {
+			rcvDescription.Store(descr)
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			RemoteConfig: &protobufs.AgentRemoteConfig{
+				ConfigHash: []byte{1, 2, 3},
+			},
+		}
+	}
+
+	// Start a client.
+	var connected int64
+	var rcvRemoteConfig atomic.Value
+	settings := StartSettings{
+		OpAMPServerURL: "http://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{
+			IdentifyingAttributes: []*protobufs.KeyValue{
+				{
+					Key: "host.name",
+					Value: &protobufs.AnyValue{
+						Value: &protobufs.AnyValue_StringValue{StringValue: "somehost"},
+					},
+				},
+			},
+		},
+		HTTPPollingInterval: 10 * time.Millisecond,
+		Callbacks: CallbacksStruct{
+			OnConnectFunc: func(serverURL string) {
+				atomic.StoreInt64(&connected, 1)
+			},
+			OnRemoteConfigFunc: func(
+				ctx context.Context,
+				config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				rcvRemoteConfig.Store(config)
+				return nil, nil
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The first request must carry the first status report.
+	eventually(t, func() bool { return atomic.LoadInt64(&connected) != 0 })
+	eventually(t, func() bool { return rcvDescription.Load() != nil })
+	assert.True(t, proto.Equal(settings.AgentDescription, rcvDescription.Load().(*protobufs.AgentDescription)))
+
+	// The response must be processed the same way as with WebSocket transport.
+	eventually(t, func() bool { return rcvRemoteConfig.Load() != nil })
+	assert.EqualValues(t, []byte{1, 2, 3}, rcvRemoteConfig.Load().(*protobufs.AgentRemoteConfig).ConfigHash)
+
+	// The client must keep polling the server when there is nothing to send.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvMessages) > 3 })
+
+	// Updates must be sent to the server.
+	newDescription := &protobufs.AgentDescription{}
+	assert.NoError(t, client.SetAgentDescription(newDescription))
+	eventually(t, func() bool {
+		return proto.Equal(newDescription, rcvDescription.Load().(*protobufs.AgentDescription))
+	})
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHTTPTransportExplicit(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvStatus int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.StatusReport != nil {
+			atomic.AddInt64(&rcvStatus, 1)
+		}
+		return nil
+	}
+
+	// Start a client. The scheme of the URL must be adjusted to the transport.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		Transport:        TransportHTTP,
+		AgentDescription: &protobufs.AgentDescription{},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHTTPTransportRetry(t *testing.T) {
+	// Start a server that fails the first request.
+	srv := internal.StartMockServer(t)
+	var requests int64
+	var rcvStatus int64
+	srv.OnRequest = func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var msg protobufs.AgentToServer
+		assert.NoError(t, proto.Unmarshal(body, &msg))
+		if msg.GetStatusReport().GetAgentDescription() != nil {
+			atomic.AddInt64(&rcvStatus, 1)
+		}
+	}
+
+	// Start a client.
+	var connectFailed int64
+	var connected int64
+	settings := StartSettings{
+		OpAMPServerURL:   "http://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnConnectFunc: func(serverURL string) {
+				atomic.StoreInt64(&connected, 1)
+			},
+			OnConnectFailedFunc: func(serverURL string, err error) {
+				atomic.StoreInt64(&connectFailed, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The failure must be reported and the first status report must be retried.
+	eventually(t, func() bool { return atomic.LoadInt64(&connectFailed) != 0 })
+	eventually(t, func() bool { return atomic.LoadInt64(&connected) != 0 })
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestSendFailureResendsMessage(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var conns []*websocket.Conn
+	var connsMux sync.Mutex
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		connsMux.Lock()
+		conns = append(conns, conn)
+		connsMux.Unlock()
+	}
+	var rcvDescription atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if descr := msg.GetStatusReport().GetAgentDescription(); descr != nil {
+			rcvDescription.Store(descr)
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid:  msg.InstanceUid,
+			RemoteConfig: &protobufs.AgentRemoteConfig{},
+		}
+	}
+
+	// Start a client. Block the callback so that the status it reports is not
+	// sent before the description is set. The client may notice that the
+	// connection is lost when receiving or when sending the description, in
+	// both cases it must reconnect and send the description.
+	remoteConfigRcvd := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnRemoteConfigFunc: func(
+				ctx context.Context,
+				config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				select {
+				case remoteConfigRcvd <- struct{}{}:
+					<-unblock
+				default:
+				}
+				return nil, nil
+			},
+		},
+	}
+	client := startClient(t, settings)
+	<-remoteConfigRcvd
+
+	// Kill the connection abruptly from the server side.
+	connsMux.Lock()
+	tcpConn := conns[0].UnderlyingConn().(*net.TCPConn)
+	connsMux.Unlock()
+	assert.NoError(t, tcpConn.SetLinger(0))
+	assert.NoError(t, tcpConn.Close())
+	time.Sleep(100 * time.Millisecond)
+
+	// The client may fail to send this over the killed connection.
+	descr := &protobufs.AgentDescription{
+		NonIdentifyingAttributes: []*protobufs.KeyValue{
+			{
+				Key: "os.family",
+				Value: &protobufs.AnyValue{
+					Value: &protobufs.AnyValue_StringValue{StringValue: "linux"},
+				},
+			},
+		},
+	}
+	assert.NoError(t, client.SetAgentDescription(descr))
+
+	// The client must reconnect and send the description again.
+	close(unblock)
+	eventually(t, func() bool {
+		connsMux.Lock()
+		defer connsMux.Unlock()
+		return len(conns) == 2
+	})
+	eventually(t, func() bool {
+		return proto.Equal(descr, rcvDescription.Load().(*protobufs.AgentDescription))
+	})
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestPingTimeoutReconnects(t *testing.T) {
+	// Start a server that stops responding on the first connection, which
+	// looks like a half-open connection to the client.
+	srv := internal.StartMockServer(t)
+	var srvConnected int64
+	unblock := make(chan struct{})
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		if atomic.AddInt64(&srvConnected, 1) == 1 {
+			<-unblock
+		}
+	}
+
+	// Start a client.
+	var connected int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		PingInterval:     50 * time.Millisecond,
+		PongTimeout:      50 * time.Millisecond,
+		Callbacks: CallbacksStruct{
+			OnConnectFunc: func(serverURL string) {
+				atomic.AddInt64(&connected, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The client must detect that the server does not respond and reconnect.
+	eventually(t, func() bool { return atomic.LoadInt64(&connected) == 2 })
+	eventually(t, func() bool { return atomic.LoadInt64(&srvConnected) == 2 })
+
+	// The new connection is healthy, so it must not be torn down.
+	time.Sleep(300 * time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt64(&connected))
+
+	close(unblock)
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestStopSendsAgentDisconnect(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var closeCode int64
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		conn.SetCloseHandler(func(code int, text string) error {
+			atomic.StoreInt64(&closeCode, int64(code))
+			return nil
+		})
+	}
+	var rcvStatus int64
+	var rcvDisconnect atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.StatusReport != nil {
+			atomic.AddInt64(&rcvStatus, 1)
+		}
+		if msg.AgentDisconnect != nil {
+			rcvDisconnect.Store(msg)
+		}
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+
+	// Make a change that is not sent yet.
+	config := &protobufs.EffectiveConfig{Hash: []byte{1, 2, 3}}
+	client.sender.UpdateNextStatus(func(statusReport *protobufs.StatusReport) {
+		statusReport.EffectiveConfig = config
+	})
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+
+	// The pending change must be sent together with the AgentDisconnect and
+	// then the connection must be closed normally.
+	eventually(t, func() bool { return rcvDisconnect.Load() != nil })
+	msg := rcvDisconnect.Load().(*protobufs.AgentToServer)
+	assert.True(t, proto.Equal(config, msg.GetStatusReport().GetEffectiveConfig()))
+	eventually(t, func() bool { return atomic.LoadInt64(&closeCode) == websocket.CloseNormalClosure })
+
+	// Shutdown the server.
+	srv.Close()
+}
+
+func TestStopSendsAgentDisconnectHTTP(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvStatus int64
+	var rcvDisconnect atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.StatusReport != nil {
+			atomic.AddInt64(&rcvStatus, 1)
+		}
+		if msg.AgentDisconnect != nil {
+			rcvDisconnect.Store(msg)
+		}
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "http://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+
+	// Shutdown the client. The AgentDisconnect must be sent before Stop returns.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+	assert.NotNil(t, rcvDisconnect.Load())
+
+	// Shutdown the server.
+	srv.Close()
+}
+
+func TestAgentCapabilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings StartSettings
+		expected protobufs.AgentCapabilities
+	}{
+		{
+			name:     "no callbacks",
+			settings: StartSettings{},
+			expected: protobufs.AgentCapabilities_ReportsStatus,
+		},
+		{
+			name: "some callbacks",
+			settings: StartSettings{
+				Callbacks: CallbacksStruct{
+					OnRemoteConfigFunc: func(
+						ctx context.Context, config *protobufs.AgentRemoteConfig,
+					) (*protobufs.EffectiveConfig, error) {
+						return nil, nil
+					},
+					OnOpampConnectionSettingsFunc: func(
+						ctx context.Context, settings *protobufs.ConnectionSettings,
+					) error {
+						return nil
+					},
+				},
+			},
+			expected: protobufs.AgentCapabilities_ReportsStatus |
+				protobufs.AgentCapabilities_AcceptsRemoteConfig |
+				protobufs.AgentCapabilities_ReportsEffectiveConfig |
+				protobufs.AgentCapabilities_AcceptsOpAMPConnectionSettings,
+		},
+		{
+			name: "state providers",
+			settings: StartSettings{
+				AddonStateProvider:        newInMemAddonStore(),
+				AgentPackageStateProvider: &inMemPackageStore{},
+			},
+			expected: protobufs.AgentCapabilities_ReportsStatus |
+				protobufs.AgentCapabilities_AcceptsAddons |
+				protobufs.AgentCapabilities_ReportsAddonsStatus |
+				protobufs.AgentCapabilities_AcceptsAgentPackage |
+				protobufs.AgentCapabilities_ReportsAgentPackageStatus,
+		},
+		{
+			name: "explicit",
+			settings: StartSettings{
+				Callbacks:    CallbacksStruct{},
+				Capabilities: protobufs.AgentCapabilities_AcceptsAddons,
+			},
+			expected: protobufs.AgentCapabilities_ReportsStatus |
+				protobufs.AgentCapabilities_AcceptsAddons,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.EqualValues(t, test.expected, agentCapabilities(&test.settings))
+		})
+	}
+}
+
+func TestServerCapabilities(t *testing.T) {
+	// Start a server that does not accept effective config.
+	srv := internal.StartMockServer(t)
+	var rcvStatus atomic.Value
+	var rcvEffectiveConfig int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.StatusReport != nil {
+			rcvStatus.Store(msg.StatusReport)
+			if msg.StatusReport.EffectiveConfig != nil {
+				atomic.AddInt64(&rcvEffectiveConfig, 1)
+			}
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid:  msg.InstanceUid,
+			Capabilities: protobufs.ServerCapabilities_AcceptsStatus,
+		}
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Capabilities:     protobufs.AgentCapabilities_ReportsEffectiveConfig,
+	}
+	client := startClient(t, settings)
+
+	// The first status report must advertise the agent's capabilities.
+	eventually(t, func() bool { return rcvStatus.Load() != nil })
+	assert.EqualValues(
+		t,
+		protobufs.AgentCapabilities_ReportsStatus|protobufs.AgentCapabilities_ReportsEffectiveConfig,
+		rcvStatus.Load().(*protobufs.StatusReport).Capabilities,
+	)
+
+	// The client must remember the server's capabilities.
+	eventually(t, func() bool {
+		return client.Capabilities().Server == protobufs.ServerCapabilities_AcceptsStatus
+	})
+
+	// The effective config must not be sent since the server does not accept it.
+	descr := &protobufs.AgentDescription{
+		NonIdentifyingAttributes: []*protobufs.KeyValue{
+			{
+				Key: "os.family",
+				Value: &protobufs.AnyValue{
+					Value: &protobufs.AnyValue_StringValue{StringValue: "linux"},
+				},
+			},
+		},
+	}
+	assert.NoError(t, client.SetEffectiveConfig(&protobufs.EffectiveConfig{Hash: []byte{1}}))
+	assert.NoError(t, client.SetAgentDescription(descr))
+	eventually(t, func() bool {
+		return proto.Equal(descr, rcvStatus.Load().(*protobufs.StatusReport).AgentDescription)
+	})
+	assert.EqualValues(t, 0, atomic.LoadInt64(&rcvEffectiveConfig))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestAddonStateProviderSyncsAutomatically(t *testing.T) {
+	addonContent := []byte("addon content")
+	fileSrv := startFileServer(t, map[string][]byte{"/addon1": addonContent})
+
+	addonsAvailable := &protobufs.AddonsAvailable{
+		Addons: map[string]*protobufs.AddonAvailable{
+			"addon1": {
+				File: &protobufs.DownloadableFile{
+					DownloadUrl: fileSrv.URL + "/addon1",
+					ContentHash: contentHash(addonContent),
+				},
+				Hash: []byte{1},
+			},
+		},
+		AllAddonsHash: []byte{1},
+	}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var offered int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if atomic.AddInt64(&offered, 1) == 1 {
+			return &protobufs.ServerToAgent{
+				InstanceUid:     msg.InstanceUid,
+				AddonsAvailable: addonsAvailable,
+			}
+		}
+		return nil
+	}
+
+	// Start a client without OnAddonsAvailable callback.
+	localState := newInMemAddonStore()
+	settings := StartSettings{
+		OpAMPServerURL:     "ws://" + srv.Endpoint,
+		AgentDescription:   &protobufs.AgentDescription{},
+		AddonStateProvider: localState,
+	}
+	client := startClient(t, settings)
+
+	// The addons must be synced using the provider.
+	eventually(t, func() bool {
+		localState.mux.Lock()
+		defer localState.mux.Unlock()
+		return bytes.Equal(localState.allAddonsHash, addonsAvailable.AllAddonsHash)
+	})
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestServerFlagsResendState(t *testing.T) {
+	effectiveConfig := &protobufs.EffectiveConfig{Hash: []byte{1, 2, 3}}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvEffectiveConfig int64
+	var rcvAddonStatuses int64
+	var askToReport int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if cfg := msg.GetStatusReport().GetEffectiveConfig(); cfg != nil {
+			assert.True(t, proto.Equal(effectiveConfig, cfg))
+			atomic.AddInt64(&rcvEffectiveConfig, 1)
+		}
+		if msg.AddonStatuses != nil {
+			assert.EqualValues(t, []byte{4, 5}, msg.AddonStatuses.ServerProvidedAllAddonsHash)
+			atomic.AddInt64(&rcvAddonStatuses, 1)
+		}
+		if atomic.LoadInt64(&askToReport) == 1 {
+			// Pretend the server lost the state and ask the agent to report it.
+			atomic.StoreInt64(&askToReport, 0)
+			return &protobufs.ServerToAgent{
+				InstanceUid: msg.InstanceUid,
+				Flags: protobufs.ServerToAgent_ReportEffectiveConfig |
+					protobufs.ServerToAgent_ReportAddonStatus,
+			}
+		}
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:                  "ws://" + srv.Endpoint,
+		AgentDescription:                &protobufs.AgentDescription{},
+		LastServerProvidedAllAddonsHash: []byte{4, 5},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvAddonStatuses) == 1 })
+
+	assert.NoError(t, client.SetEffectiveConfig(effectiveConfig))
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvEffectiveConfig) == 1 })
+
+	// Make the server ask for the state in response to the next message.
+	atomic.StoreInt64(&askToReport, 1)
+	assert.NoError(t, client.SetAgentDescription(&protobufs.AgentDescription{}))
+
+	// The client must report the last known state again.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvEffectiveConfig) == 2 })
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvAddonStatuses) == 2 })
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func testRemoteConfigStatus(t *testing.T, applyErr error, expectedStatus *protobufs.RemoteConfigStatus) {
+	remoteConfig := &protobufs.AgentRemoteConfig{
+		Config:     &protobufs.AgentConfigMap{},
+		ConfigHash: []byte{1, 2, 3},
+	}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var offered int64
+	var rcvApplying int64
+	var rcvStatus atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if status := msg.GetStatusReport().GetRemoteConfigStatus(); status != nil {
+			if status.Status == protobufs.RemoteConfigStatus_Applying {
+				assert.EqualValues(t, remoteConfig.ConfigHash, status.LastRemoteConfigHash)
+				atomic.StoreInt64(&rcvApplying, 1)
+			} else {
+				rcvStatus.Store(status)
+			}
+		}
+		if atomic.AddInt64(&offered, 1) == 1 {
+			return &protobufs.ServerToAgent{
+				InstanceUid:  msg.InstanceUid,
+				RemoteConfig: remoteConfig,
+			}
+		}
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnRemoteConfigFunc: func(
+				ctx context.Context, config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				if config == nil {
+					return nil, nil
+				}
+				// The server must be told that the config is being applied.
+				eventually(t, func() bool { return atomic.LoadInt64(&rcvApplying) == 1 })
+				return &protobufs.EffectiveConfig{}, applyErr
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The result of applying must be reported.
+	eventually(t, func() bool { return rcvStatus.Load() != nil })
+	assert.True(t, proto.Equal(expectedStatus, rcvStatus.Load().(*protobufs.RemoteConfigStatus)))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRemoteConfigStatusApplied(t *testing.T) {
+	testRemoteConfigStatus(t, nil, &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: []byte{1, 2, 3},
+		Status:               protobufs.RemoteConfigStatus_Applied,
+	})
+}
+
+func TestRemoteConfigStatusFailed(t *testing.T) {
+	testRemoteConfigStatus(t, errors.New("invalid config"), &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: []byte{1, 2, 3},
+		Status:               protobufs.RemoteConfigStatus_Failed,
+		ErrorMessage:         "invalid config",
+	})
+}
+
+func TestFirstStatusReportLastState(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvStatus atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.StatusReport != nil && rcvStatus.Load() == nil {
+			rcvStatus.Store(msg.StatusReport)
+		}
+		return nil
+	}
+
+	// Start a client with previously saved state.
+	settings := StartSettings{
+		OpAMPServerURL:       "ws://" + srv.Endpoint,
+		AgentDescription:     &protobufs.AgentDescription{},
+		LastRemoteConfigHash: []byte{1, 2, 3},
+		LastEffectiveConfig:  &protobufs.EffectiveConfig{Hash: []byte{4, 5, 6}},
+	}
+	client := startClient(t, settings)
+
+	// The saved state must be reported in the first status report.
+	eventually(t, func() bool { return rcvStatus.Load() != nil })
+	status := rcvStatus.Load().(*protobufs.StatusReport)
+	assert.EqualValues(t, settings.LastRemoteConfigHash, status.GetRemoteConfigStatus().GetLastRemoteConfigHash())
+	assert.True(t, proto.Equal(settings.LastEffectiveConfig, status.EffectiveConfig))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRemoteConfigStatusAfterReconnect(t *testing.T) {
+	remoteConfig := &protobufs.AgentRemoteConfig{
+		Config:     &protobufs.AgentConfigMap{},
+		ConfigHash: []byte{1, 2, 3},
+	}
+
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var conn atomic.Value
+	var connected int64
+	srv.OnConnect = func(r *http.Request, c *websocket.Conn) {
+		conn.Store(c)
+		atomic.AddInt64(&connected, 1)
+	}
+	var rcvApplied int64
+	var rcvStatusAfterReconnect atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		status := msg.GetStatusReport().GetRemoteConfigStatus()
+		if atomic.LoadInt64(&connected) == 1 {
+			if status != nil && status.Status == protobufs.RemoteConfigStatus_Applied {
+				atomic.StoreInt64(&rcvApplied, 1)
+				return nil
+			}
+			return &protobufs.ServerToAgent{
+				InstanceUid:  msg.InstanceUid,
+				RemoteConfig: remoteConfig,
+			}
+		}
+		if status != nil {
+			rcvStatusAfterReconnect.Store(status)
+		}
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnRemoteConfigFunc: func(
+				ctx context.Context, config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				return nil, nil
+			},
+		},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvApplied) == 1 })
+
+	// Close the connection to make the client reconnect.
+	conn.Load().(*websocket.Conn).Close()
+
+	// The client must report the applied config after reconnecting.
+	eventually(t, func() bool { return rcvStatusAfterReconnect.Load() != nil })
+	status := rcvStatusAfterReconnect.Load().(*protobufs.RemoteConfigStatus)
+	assert.EqualValues(t, remoteConfig.ConfigHash, status.LastRemoteConfigHash)
+	assert.EqualValues(t, protobufs.RemoteConfigStatus_Applied, status.Status)
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestRemoteConfigCallbackDoesNotBlockReceiving(t *testing.T) {
+	// Start a server that offers a new remote config in response to each of
+	// the first 3 messages and reports an error in response to the 3rd one.
+	srv := internal.StartMockServer(t)
+	var msgCount int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		n := atomic.AddInt64(&msgCount, 1)
+		if n > 3 {
+			return nil
+		}
+		response := &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			RemoteConfig: &protobufs.AgentRemoteConfig{
+				Config:     &protobufs.AgentConfigMap{},
+				ConfigHash: []byte{byte(n)},
+			},
+		}
+		if n == 3 {
+			response.ErrorResponse = &protobufs.ServerErrorResponse{ErrorMessage: "test error"}
+		}
+		return response
+	}
+
+	// Start a client that blocks in the first OnRemoteConfig call.
+	unblock := make(chan struct{})
+	var rcvErr int64
+	var hashesMux sync.Mutex
+	var hashes [][]byte
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnRemoteConfigFunc: func(
+				ctx context.Context,
+				config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				if config == nil {
+					return nil, nil
+				}
+				hashesMux.Lock()
+				hashes = append(hashes, config.ConfigHash)
+				first := len(hashes) == 1
+				hashesMux.Unlock()
+				if first {
+					<-unblock
+				}
+				return nil, nil
+			},
+			OnErrorFunc: func(err *protobufs.ServerErrorResponse) {
+				atomic.StoreInt64(&rcvErr, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// Wait until the server receives the Applying status of the first config
+	// and responds with the second config.
+	eventually(t, func() bool { return atomic.LoadInt64(&msgCount) == 2 })
+
+	// Make the server respond with the third config and the error.
+	assert.NoError(t, client.SetAgentDescription(&protobufs.AgentDescription{}))
+
+	// The error must be received while the first config is still being applied.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvErr) == 1 })
+
+	// The second config is superseded by the third one and must be skipped.
+	close(unblock)
+	eventually(t, func() bool {
+		hashesMux.Lock()
+		defer hashesMux.Unlock()
+		return len(hashes) == 2
+	})
+	hashesMux.Lock()
+	assert.EqualValues(t, [][]byte{{1}, {3}}, hashes)
+	hashesMux.Unlock()
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestStopCancelsCallbacks(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		return &protobufs.ServerToAgent{
+			InstanceUid:  msg.InstanceUid,
+			RemoteConfig: &protobufs.AgentRemoteConfig{},
+		}
+	}
+
+	// Start a client with a callback that returns only when cancelled.
+	var rcvConfig int64
+	var cancelled int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnRemoteConfigFunc: func(
+				ctx context.Context,
+				config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				atomic.StoreInt64(&rcvConfig, 1)
+				<-ctx.Done()
+				time.Sleep(50 * time.Millisecond)
+				atomic.StoreInt64(&cancelled, 1)
+				return nil, ctx.Err()
+			},
+		},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvConfig) == 1 })
+
+	// Stop must cancel the callback and wait until it returns.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt64(&cancelled))
+
+	// Shutdown the server.
+	srv.Close()
+}
+
+func TestStateStore(t *testing.T) {
+	remoteConfig := &protobufs.AgentRemoteConfig{
+		Config:     &protobufs.AgentConfigMap{},
+		ConfigHash: []byte{1, 2, 3},
+	}
+	effectiveConfig := &protobufs.EffectiveConfig{Hash: []byte{4, 5, 6}}
+
+	// Start a server that sends the remote config until the agent reports that
+	// it has it.
+	srv := internal.StartMockServer(t)
+	var rcvHash atomic.Value
+	var rcvEffectiveConfig atomic.Value
+	var rcvInstanceUid atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		rcvInstanceUid.Store(msg.InstanceUid)
+		if status := msg.GetStatusReport().GetRemoteConfigStatus(); status != nil {
+			rcvHash.Store(status.LastRemoteConfigHash)
+		}
+		if effective := msg.GetStatusReport().GetEffectiveConfig(); effective != nil {
+			rcvEffectiveConfig.Store(effective)
+		}
+		response := &protobufs.ServerToAgent{InstanceUid: msg.InstanceUid}
+		if hash, _ := rcvHash.Load().([]byte); !bytes.Equal(hash, remoteConfig.ConfigHash) {
+			response.RemoteConfig = remoteConfig
+		}
+		return response
+	}
+
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	var rcvConfigCount int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		StateStore:       store,
+		Callbacks: CallbacksStruct{
+			OnRemoteConfigFunc: func(
+				ctx context.Context, config *protobufs.AgentRemoteConfig,
+			) (*protobufs.EffectiveConfig, error) {
+				if config != nil {
+					atomic.AddInt64(&rcvConfigCount, 1)
+				}
+				return effectiveConfig, nil
+			},
+		},
+	}
+
+	// Start a client without InstanceUid, the client must generate it and
+	// save the applied config.
+	client := New(nil)
+	assert.NoError(t, client.Start(settings))
+	eventually(t, func() bool {
+		state, err := store.Load()
+		return err == nil && state != nil &&
+			bytes.Equal(state.LastRemoteConfigHash, remoteConfig.ConfigHash) &&
+			proto.Equal(state.LastEffectiveConfig, effectiveConfig)
+	})
+	assert.NoError(t, client.Stop(context.Background()))
+
+	state, err := store.Load()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, state.InstanceUid)
+	assert.EqualValues(t, state.InstanceUid, rcvInstanceUid.Load())
+
+	// Restart the client. It must use the saved state, so the server has no
+	// reason to send the config again.
+	rcvHash = atomic.Value{}
+	rcvEffectiveConfig = atomic.Value{}
+	client = New(nil)
+	assert.NoError(t, client.Start(settings))
+	eventually(t, func() bool {
+		hash, _ := rcvHash.Load().([]byte)
+		return bytes.Equal(hash, remoteConfig.ConfigHash) && rcvEffectiveConfig.Load() != nil
+	})
+	assert.True(t, proto.Equal(effectiveConfig, rcvEffectiveConfig.Load().(*protobufs.EffectiveConfig)))
+	assert.EqualValues(t, state.InstanceUid, rcvInstanceUid.Load())
+	assert.EqualValues(t, 1, atomic.LoadInt64(&rcvConfigCount))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err = client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+// blockingStateStore is a types.StateStore with Save that blocks until
+// unblocked.
+type blockingStateStore struct {
+	unblock chan struct{}
+	mutex   sync.Mutex
+	saved   []types.State
+}
+
+func (s *blockingStateStore) Load() (*types.State, error) {
+	return nil, nil
+}
+
+func (s *blockingStateStore) Save(state *types.State) error {
+	<-s.unblock
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.saved = append(s.saved, *state)
+	return nil
+}
+
+func (s *blockingStateStore) savedStates() []types.State {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]types.State{}, s.saved...)
+}
+
+func TestStateSaverDoesNotBlock(t *testing.T) {
+	store := &blockingStateStore{unblock: make(chan struct{})}
+	saver := newStateSaver(logging.NopLogger{}, store, "uid", types.State{})
+
+	// The updates must return while the state is being saved.
+	for i := byte(1); i <= 3; i++ {
+		saver.update(&protobufs.AgentToServer{
+			StatusReport: &protobufs.StatusReport{
+				RemoteConfigStatus: &protobufs.RemoteConfigStatus{
+					LastRemoteConfigHash: []byte{i},
+					Status:               protobufs.RemoteConfigStatus_Applied,
+				},
+			},
+		})
+	}
+	close(store.unblock)
+	assert.NoError(t, saver.wait(context.Background()))
+
+	// The states received while saving are coalesced, only the most recent
+	// one is saved.
+	saved := store.savedStates()
+	assert.True(t, len(saved) >= 1 && len(saved) <= 2)
+	assert.EqualValues(t, []byte{3}, saved[len(saved)-1].LastRemoteConfigHash)
+	assert.EqualValues(t, "uid", saved[len(saved)-1].InstanceUid)
+}
+
+// failingStateStore is a types.StateStore with Save that fails while failing
+// is set.
+type failingStateStore struct {
+	failing int64
+	saved   atomic.Value
+}
+
+func (s *failingStateStore) Load() (*types.State, error) {
+	return nil, nil
+}
+
+func (s *failingStateStore) Save(state *types.State) error {
+	if atomic.LoadInt64(&s.failing) != 0 {
+		return errors.New("cannot save")
+	}
+	s.saved.Store(*state)
+	return nil
+}
+
+func TestStateSaverRetriesFailedSave(t *testing.T) {
+	store := &failingStateStore{failing: 1}
+	saver := newStateSaver(logging.NopLogger{}, store, "uid", types.State{})
+
+	fullState := &protobufs.AgentToServer{
+		StatusReport: &protobufs.StatusReport{
+			RemoteConfigStatus: &protobufs.RemoteConfigStatus{
+				LastRemoteConfigHash: []byte{1},
+				Status:               protobufs.RemoteConfigStatus_Applied,
+			},
+		},
+	}
+	saver.update(fullState)
+
+	// Waiting must report that the state is not saved.
+	assert.Error(t, saver.wait(context.Background()))
+	assert.Nil(t, store.saved.Load())
+
+	// The next update must save the state even though it did not change.
+	atomic.StoreInt64(&store.failing, 0)
+	saver.update(fullState)
+	assert.NoError(t, saver.wait(context.Background()))
+	saved, _ := store.saved.Load().(types.State)
+	assert.EqualValues(t, []byte{1}, saved.LastRemoteConfigHash)
+}
+
+func TestStateSaverWaitRetriesFailedSave(t *testing.T) {
+	store := &failingStateStore{failing: 1}
+	saver := newStateSaver(logging.NopLogger{}, store, "uid", types.State{})
+
+	saver.update(&protobufs.AgentToServer{
+		AddonStatuses: &protobufs.AgentAddonStatuses{ServerProvidedAllAddonsHash: []byte{1}},
+	})
+	assert.Error(t, saver.wait(context.Background()))
+
+	// Waiting must save the state that failed to save.
+	atomic.StoreInt64(&store.failing, 0)
+	assert.NoError(t, saver.wait(context.Background()))
+	saved, _ := store.saved.Load().(types.State)
+	assert.EqualValues(t, []byte{1}, saved.LastServerProvidedAllAddonsHash)
+}
+
+func TestStateStoreRejectedConnectionSettings(t *testing.T) {
+	// Start a server that offers connection settings that are rejected.
+	srv := internal.StartMockServer(t)
+	var rcvStatus atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if statuses := msg.GetStatusReport().GetConnectionStatuses(); statuses.GetOpamp() != nil {
+			rcvStatus.Store(statuses.Opamp.Status)
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ConnectionSettings: &protobufs.ConnectionSettingsOffers{
+				Hash:  []byte{1},
+				Opamp: &protobufs.ConnectionSettings{},
+			},
+		}
+	}
+
+	store := NewFileStateStore(filepath.Join(t.TempDir(), "state.json"))
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		StateStore:       store,
+		Callbacks: CallbacksStruct{
+			OnOpampConnectionSettingsFunc: func(
+				ctx context.Context, settings *protobufs.ConnectionSettings,
+			) error {
+				return errors.New("rejected")
+			},
+		},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool {
+		status, _ := rcvStatus.Load().(protobufs.ConnectionStatus_Status)
+		return status == protobufs.ConnectionStatus_Rejected
+	})
+	assert.NoError(t, client.Stop(context.Background()))
+
+	// The hash of the rejected offer must not be saved, so that the server
+	// offers the settings again after restart.
+	state, err := store.Load()
+	assert.NoError(t, err)
+	assert.NotNil(t, state)
+	assert.Nil(t, state.LastConnectionSettingsHash)
+
+	srv.Close()
+}
+
+func TestConnectThroughProxy(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var srvConnected int64
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		atomic.AddInt64(&srvConnected, 1)
+	}
+
+	// Start a proxy.
+	proxy := internal.StartMockProxy(t)
+	var proxyRcvHeader atomic.Value
+	proxy.OnConnect = func(r *http.Request) {
+		assert.EqualValues(t, srv.Endpoint, r.Host)
+		proxyRcvHeader.Store(r.Header)
+	}
+
+	// Start a client that connects through the proxy.
+	var connected int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		ProxyURL:         "http://user:password@" + proxy.Endpoint,
+		ProxyHeader:      http.Header{"X-Proxy-Token": []string{"abc"}},
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnConnectFunc: func(serverURL string) {
+				atomic.StoreInt64(&connected, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return atomic.LoadInt64(&connected) == 1 })
+	assert.EqualValues(t, 1, atomic.LoadInt64(&srvConnected))
+
+	// The proxy must receive the headers in the CONNECT request.
+	header := proxyRcvHeader.Load().(http.Header)
+	assert.EqualValues(t, "abc", header.Get("X-Proxy-Token"))
+	assert.EqualValues(t, "Basic dXNlcjpwYXNzd29yZA==", header.Get("Proxy-Authorization"))
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+
+	// Shutdown the server and the proxy.
+	srv.Close()
+	proxy.Close()
+}
+
+func TestOpampConnectionSettingsProxy(t *testing.T) {
+	// Start a proxy.
+	proxy := internal.StartMockProxy(t)
+	var proxyConnected int64
+	proxy.OnConnect = func(r *http.Request) {
+		assert.EqualValues(t, "abc", r.Header.Get("X-Proxy-Token"))
+		atomic.AddInt64(&proxyConnected, 1)
+	}
+
+	opampSettings := &protobufs.ConnectionSettings{
+		ProxyEndpoint: "http://" + proxy.Endpoint,
+		ProxyHeaders: &protobufs.Headers{
+			Headers: []*protobufs.Header{{Key: "X-Proxy-Token", Value: "abc"}},
+		},
+		Flags: protobufs.ConnectionSettings_ProxyEndpointSet,
+	}
+
+	// Start the server that offers to connect to it through the proxy.
+	srv := internal.StartMockServer(t)
+	var srvConnected int64
+	srv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		atomic.AddInt64(&srvConnected, 1)
+	}
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ConnectionSettings: &protobufs.ConnectionSettingsOffers{
+				Hash:  []byte{1},
+				Opamp: opampSettings,
+			},
+		}
+	}
+
+	// Start a client.
+	var accepted atomic.Value
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnOpampConnectionSettingsAcceptedFunc: func(settings *protobufs.ConnectionSettings) {
+				accepted.Store(settings)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	// The client must switch to connecting through the proxy. The same offer
+	// received again must not make the client reconnect.
+	eventually(t, func() bool { return accepted.Load() != nil })
+	assert.EqualValues(t, 1, atomic.LoadInt64(&proxyConnected))
+	time.Sleep(100 * time.Millisecond)
+	assert.EqualValues(t, 2, atomic.LoadInt64(&srvConnected))
+	assert.EqualValues(t, 1, atomic.LoadInt64(&proxyConnected))
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+
+	// Shutdown the server and the proxy.
+	srv.Close()
+	proxy.Close()
+}
+
+// createTestCert creates a certificate for 127.0.0.1 signed by the CA, or a
+// self-signed CA certificate if ca is nil. Returns the certificate and the
+// private key in DER encoding.
+func createTestCert(
+	t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey,
+) (cert *x509.Certificate, key *ecdsa.PrivateKey, certDER []byte, keyDER []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	if ca == nil {
+		template.Subject.CommonName = "ca"
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		ca, caKey = template, key
+	}
+
+	certDER, err = x509.CreateCertificate(crand.Reader, template, ca, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+	cert, err = x509.ParseCertificate(certDER)
+	assert.NoError(t, err)
+	keyDER, err = x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return cert, key, certDER, keyDER
+}
+
+func encodePEM(blockType string, der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	ca, caKey, caDER, _ := createTestCert(t, nil, nil)
+	_, _, certDER, keyDER := createTestCert(t, ca, caKey)
+	_, _, _, otherKeyDER := createTestCert(t, ca, caKey)
+
+	tests := []struct {
+		name        string
+		certificate *protobufs.TLSCertificate
+		expectError bool
+	}{
+		{
+			name: "PEM",
+			certificate: &protobufs.TLSCertificate{
+				PublicKey:   encodePEM("CERTIFICATE", certDER),
+				PrivateKey:  encodePEM("PRIVATE KEY", keyDER),
+				CaPublicKey: encodePEM("CERTIFICATE", caDER),
+			},
+		},
+		{
+			name: "DER",
+			certificate: &protobufs.TLSCertificate{
+				PublicKey:   certDER,
+				PrivateKey:  keyDER,
+				CaPublicKey: caDER,
+			},
+		},
+		{
+			name: "without CA",
+			certificate: &protobufs.TLSCertificate{
+				PublicKey:  certDER,
+				PrivateKey: keyDER,
+			},
+		},
+		{
+			name: "key does not match",
+			certificate: &protobufs.TLSCertificate{
+				PublicKey:  certDER,
+				PrivateKey: otherKeyDER,
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid certificate",
+			certificate: &protobufs.TLSCertificate{
+				PublicKey:  []byte("invalid"),
+				PrivateKey: keyDER,
+			},
+			expectError: true,
+		},
+		{
+			name: "invalid CA",
+			certificate: &protobufs.TLSCertificate{
+				PublicKey:   certDER,
+				PrivateKey:  keyDER,
+				CaPublicKey: []byte("invalid"),
+			},
+			expectError: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := NewTLSConfig(test.certificate)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, cfg.Certificates, 1)
+			assert.EqualValues(t, certDER, cfg.Certificates[0].Certificate[0])
+			// The CA of the client certificate must not be trusted.
+			assert.Nil(t, cfg.RootCAs)
+		})
+	}
+}
+
+// testOpampConnectionSettingsCertificate offers the client the settings to
+// connect to a server that requires a client certificate. The certificate of
+// the server and the offered client certificate are signed by the offered CA.
+// The client trusts the CA only if trustCA is set.
+func testOpampConnectionSettingsCertificate(t *testing.T, trustCA bool) {
+	ca, caKey, caDER, _ := createTestCert(t, nil, nil)
+	_, srvKey, srvCertDER, _ := createTestCert(t, ca, caKey)
+	_, _, agentCertDER, agentKeyDER := createTestCert(t, ca, caKey)
+	srvCert := tls.Certificate{Certificate: [][]byte{srvCertDER}, PrivateKey: srvKey}
+
+	// Start the server that requires a client certificate signed by the CA.
+	caPool := x509.NewCertPool()
+	caPool.AddCert(ca)
+	tlsSrv := internal.StartTLSMockServer(t, &tls.Config{
+		Certificates: []tls.Certificate{srvCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    caPool,
+	})
+	var tlsSrvConnected int64
+	tlsSrv.OnConnect = func(r *http.Request, conn *websocket.Conn) {
+		assert.EqualValues(t, "agent", r.TLS.PeerCertificates[0].Subject.CommonName)
+		atomic.AddInt64(&tlsSrvConnected, 1)
+	}
+
+	// Start the server that offers the certificate.
+	opampSettings := &protobufs.ConnectionSettings{
+		DestinationEndpoint: "wss://" + tlsSrv.Endpoint,
+		Certificate: &protobufs.TLSCertificate{
+			PublicKey:   encodePEM("CERTIFICATE", agentCertDER),
+			PrivateKey:  encodePEM("PRIVATE KEY", agentKeyDER),
+			CaPublicKey: encodePEM("CERTIFICATE", caDER),
+		},
+		Flags: protobufs.ConnectionSettings_DestinationEndpointSet,
+	}
+	var srv *internal.MockServer
+	var serverURL string
+	var tlsConfig *tls.Config
+	if trustCA {
+		srv = internal.StartTLSMockServer(t, &tls.Config{Certificates: []tls.Certificate{srvCert}})
+		serverURL = "wss://" + srv.Endpoint
+		tlsConfig = &tls.Config{RootCAs: caPool}
+	} else {
+		srv = internal.StartMockServer(t)
+		serverURL = "ws://" + srv.Endpoint
+	}
+	var rcvConnStatuses atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if statuses := msg.GetStatusReport().GetConnectionStatuses(); statuses != nil {
+			rcvConnStatuses.Store(statuses)
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			ConnectionSettings: &protobufs.ConnectionSettingsOffers{
+				Hash:  []byte{1},
+				Opamp: opampSettings,
+			},
+		}
+	}
+
+	// Start a client.
+	var accepted int64
+	settings := StartSettings{
+		OpAMPServerURL:   serverURL,
+		TLSConfig:        tlsConfig,
+		AgentDescription: &protobufs.AgentDescription{},
+		Callbacks: CallbacksStruct{
+			OnOpampConnectionSettingsAcceptedFunc: func(settings *protobufs.ConnectionSettings) {
+				atomic.StoreInt64(&accepted, 1)
+			},
+		},
+	}
+	client := startClient(t, settings)
+
+	if trustCA {
+		// The client must connect to the server using the offered certificate
+		// and verify the server using the configured roots.
+		eventually(t, func() bool { return atomic.LoadInt64(&accepted) == 1 })
+		assert.EqualValues(t, 1, atomic.LoadInt64(&tlsSrvConnected))
+	} else {
+		// The offered CA must not be used to verify the server.
+		eventually(t, func() bool { return rcvConnStatuses.Load() != nil })
+		statuses := rcvConnStatuses.Load().(*protobufs.ConnectionStatuses)
+		assert.EqualValues(t, protobufs.ConnectionStatus_Rejected, statuses.Opamp.Status)
+		assert.EqualValues(t, 0, atomic.LoadInt64(&accepted))
+		assert.EqualValues(t, 0, atomic.LoadInt64(&tlsSrvConnected))
+	}
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+
+	// Shutdown the servers.
+	srv.Close()
+	tlsSrv.Close()
+}
+
+func TestOpampConnectionSettingsCertificate(t *testing.T) {
+	testOpampConnectionSettingsCertificate(t, true)
+}
+
+func TestOpampConnectionSettingsOfferedCANotTrusted(t *testing.T) {
+	testOpampConnectionSettingsCertificate(t, false)
+}
+
+func TestStatusNoServer(t *testing.T) {
+	client := New(nil)
+	assert.Equal(t, types.StateStopped, client.Status().State)
+
+	// Start a client that cannot connect.
+	settings := createNoServerSettings()
+	assert.NoError(t, client.Start(settings))
+
+	// The client must be waiting to retry after the failed attempt.
+	eventually(t, func() bool { return client.Status().State == types.StateBackoff })
+	status := client.Status()
+	assert.EqualValues(t, settings.OpAMPServerURL, status.ServerURL)
+	assert.Error(t, status.LastError)
+	assert.True(t, status.ConnectedAt.IsZero())
+	assert.EqualValues(t, 0, status.MessagesSent)
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.StateStopped, client.Status().State)
+}
+
+func TestStatus(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var conn atomic.Value
+	srv.OnConnect = func(r *http.Request, c *websocket.Conn) {
+		conn.Store(c)
+	}
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		return &protobufs.ServerToAgent{
+			InstanceUid:  msg.InstanceUid,
+			Capabilities: protobufs.ServerCapabilities_AcceptsStatus,
+		}
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+	}
+	client := startClient(t, settings)
+
+	eventually(t, func() bool { return client.Status().MessagesReceived == 1 })
+	status := client.Status()
+	assert.Equal(t, types.StateConnected, status.State)
+	assert.EqualValues(t, settings.OpAMPServerURL, status.ServerURL)
+	assert.False(t, status.ConnectedAt.IsZero())
+	assert.EqualValues(t, 0, status.ReconnectCount)
+	assert.EqualValues(t, 1, status.MessagesSent)
+	assert.True(t, status.BytesSent > 0)
+	assert.True(t, status.BytesReceived > 0)
+
+	// Close the connection to make the client reconnect and send the full
+	// state over the new connection.
+	conn.Load().(*websocket.Conn).Close()
+	eventually(t, func() bool { return client.Status().MessagesReceived == 2 })
+	status = client.Status()
+	assert.Equal(t, types.StateConnected, status.State)
+	assert.EqualValues(t, 1, status.ReconnectCount)
+	assert.EqualValues(t, 2, status.MessagesSent)
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, types.StateStopped, client.Status().State)
+}
+
+// fakeClock is an internal.Clock that lets the tests control the time.
+// The timers fire only when fired by the test.
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers chan *fakeTimer
+	// The number of the timers that fired immediately.
+	expired int64
+}
+
+type fakeTimer struct {
+	d time.Duration
+	c chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now(), timers: make(chan *fakeTimer, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) internal.Timer {
+	timer := &fakeTimer{d: d, c: make(chan time.Time, 1)}
+	if d <= 0 {
+		atomic.AddInt64(&c.expired, 1)
+		timer.c <- c.Now()
+		return timer
+	}
+	c.timers <- timer
+	return timer
+}
+
+// nextTimer waits until the client starts a timer.
+func (c *fakeClock) nextTimer(t *testing.T) *fakeTimer {
+	select {
+	case timer := <-c.timers:
+		return timer
+	case <-time.After(5 * time.Second):
+		t.Fatal("timer was not started")
+		return nil
+	}
+}
+
+// fire advances the clock to the timer expiration and fires the timer.
+func (c *fakeClock) fire(timer *fakeTimer) {
+	c.Advance(timer.d)
+	timer.c <- c.Now()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return true
+}
+
+func TestFailbackWaitsWhenNotConnected(t *testing.T) {
+	clock := newFakeClock()
+
+	// Prepare a client that failed over long ago and is not connected.
+	client := New(nil)
+	client.clock = clock
+	client.settings.FailbackAfter = time.Minute
+	connSettings, err := newConnectionSettings(&StartSettings{
+		OpAMPServerURL:     "ws://127.0.0.1:4320",
+		FailoverServerURLs: []string{"ws://127.0.0.1:4321"},
+	})
+	assert.NoError(t, err)
+	client.connSettings = connSettings.withEndpoint(1)
+	client.failedOverAt = clock.Now().Add(-time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		client.runFailback(ctx)
+		close(stopped)
+	}()
+
+	// The client must not fail back since it is not connected, and must wait
+	// for another FailbackAfter instead of checking again immediately.
+	timer := clock.nextTimer(t)
+	assert.EqualValues(t, time.Minute, timer.d)
+	assert.EqualValues(t, 1, atomic.LoadInt64(&clock.expired))
+	assert.EqualValues(t, 1, client.connSettings.endpoint)
+
+	cancel()
+	<-stopped
+}
+
+func TestBackoffSettings(t *testing.T) {
+	clock := newFakeClock()
+
+	// Start a client that cannot connect.
+	settings := createNoServerSettings()
+	settings.Backoff = BackoffSettings{
+		InitialInterval:     time.Hour,
+		MaxInterval:         3 * time.Hour,
+		Multiplier:          2,
+		RandomizationFactor: -1,
+	}
+	client := New(nil)
+	client.clock = clock
+	assert.NoError(t, client.Start(settings))
+
+	// The intervals must grow exponentially up to the maximum.
+	for _, expected := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, 3 * time.Hour} {
+		timer := clock.nextTimer(t)
+		assert.EqualValues(t, expected, timer.d)
+		assert.EqualValues(t, types.StateBackoff, client.Status().State)
+		clock.fire(timer)
+	}
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestBackoffFullJitter(t *testing.T) {
+	clock := newFakeClock()
+
+	// Start a client that cannot connect.
+	settings := createNoServerSettings()
+	settings.Backoff = BackoffSettings{
+		InitialInterval: time.Hour,
+		MaxInterval:     100 * time.Hour,
+		Multiplier:      2,
+	}
+	client := New(nil)
+	client.clock = clock
+	assert.NoError(t, client.Start(settings))
+
+	// The intervals must be random, but not exceed the exponentially growing
+	// maximum.
+	maxInterval := time.Hour
+	var intervals, maxIntervals []time.Duration
+	for i := 0; i < 5; i++ {
+		timer := clock.nextTimer(t)
+		assert.True(t, timer.d <= maxInterval, "interval %v exceeds %v", timer.d, maxInterval)
+		intervals = append(intervals, timer.d)
+		maxIntervals = append(maxIntervals, maxInterval)
+		maxInterval *= 2
+		clock.fire(timer)
+	}
+	assert.NotEqualValues(t, maxIntervals, intervals)
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestBackoffHonoursRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+
+	// Start a server that asks to retry in 2 hours.
+	srv := internal.StartMockServer(t)
+	srv.OnRequest = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(internal.RetryAfterHTTPHeader, "7200")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Backoff:          BackoffSettings{InitialInterval: time.Second},
+	}
+	client := prepareClient(&settings)
+	client.clock = clock
+	assert.NoError(t, client.Start(settings))
+
+	timer := clock.nextTimer(t)
+	assert.EqualValues(t, 2*time.Hour, timer.d)
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+// writeFailingConn is a net.Conn that fails all writes after the first one.
+type writeFailingConn struct {
+	net.Conn
+	writes int64
+}
+
+func (c *writeFailingConn) Write(b []byte) (int, error) {
+	if atomic.AddInt64(&c.writes, 1) > 1 {
+		return 0, errors.New("write failed")
+	}
+	return c.Conn.Write(b)
+}
+
+func TestRetryAfterNotReusedAfterFailedFirstReport(t *testing.T) {
+	clock := newFakeClock()
+
+	// Start a server that asks to retry in an hour.
+	srv := internal.StartMockServer(t)
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		return unavailableResponse(msg)
+	}
+
+	// Start a client. The first status report sent on the second connection
+	// fails, the handshake succeeds.
+	var dials int64
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		AgentDescription: &protobufs.AgentDescription{},
+		Backoff:          BackoffSettings{InitialInterval: time.Second, RandomizationFactor: -1},
+		NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil || atomic.AddInt64(&dials, 1) != 2 {
+				return conn, err
+			}
+			return &writeFailingConn{Conn: conn}, nil
+		},
+	}
+	client := prepareClient(&settings)
+	client.clock = clock
+	assert.NoError(t, client.Start(settings))
+
+	timer := clock.nextTimer(t)
+	assert.EqualValues(t, time.Hour, timer.d)
+	clock.fire(timer)
+
+	// The reconnection after the failed report must use the backoff, not the
+	// time requested by the server for the previous connection.
+	timer = clock.nextTimer(t)
+	assert.EqualValues(t, 2, atomic.LoadInt64(&dials))
+	assert.True(t, timer.d < time.Hour, "unexpected interval %v", timer.d)
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestBackoffResetAfterStableConnection(t *testing.T) {
+	clock := newFakeClock()
+	b := newRetryBackoff(
+		BackoffSettings{
+			InitialInterval:     time.Second,
+			MaxInterval:         time.Hour,
+			Multiplier:          2,
+			RandomizationFactor: -1,
+			ResetAfter:          time.Minute,
+		},
+		clock,
+	)
+
+	assert.EqualValues(t, time.Second, b.NextInterval(internal.OptionalDuration{}))
+	assert.EqualValues(t, 2*time.Second, b.NextInterval(internal.OptionalDuration{}))
+
+	// A connection that is lost soon must not reset the backoff.
+	b.Connected()
+	clock.Advance(30 * time.Second)
+	b.Disconnected()
+	assert.EqualValues(t, 4*time.Second, b.NextInterval(internal.OptionalDuration{}))
+
+	// A stable connection must reset the backoff.
+	b.Connected()
+	clock.Advance(time.Minute)
+	b.Disconnected()
+	assert.EqualValues(t, time.Second, b.NextInterval(internal.OptionalDuration{}))
+}
+
+func TestFullStateAfterReconnect(t *testing.T) {
+	// Start a server that records the first message received on the second
+	// connection.
+	srv := internal.StartMockServer(t)
+	var conn atomic.Value
+	var connected int64
+	srv.OnConnect = func(r *http.Request, c *websocket.Conn) {
+		conn.Store(c)
+		atomic.AddInt64(&connected, 1)
+	}
+	var rcvFirstConn int64
+	var rcvAfterReconnect atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if atomic.LoadInt64(&connected) == 1 {
+			atomic.AddInt64(&rcvFirstConn, 1)
+		} else if rcvAfterReconnect.Load() == nil {
+			rcvAfterReconnect.Store(msg)
+		}
+		return &protobufs.ServerToAgent{
+			InstanceUid: msg.InstanceUid,
+			Capabilities: protobufs.ServerCapabilities_AcceptsStatus |
+				protobufs.ServerCapabilities_AcceptsEffectiveConfig |
+				protobufs.ServerCapabilities_AcceptsAddonsStatus,
+		}
+	}
+
+	// Start a client.
+	effectiveConfig := &protobufs.EffectiveConfig{
+		Hash:      []byte{1, 2, 3},
+		ConfigMap: &protobufs.AgentConfigMap{},
+	}
+	settings := StartSettings{
+		OpAMPServerURL:                  "ws://" + srv.Endpoint,
+		InstanceUid:                     "01BX5ZZKBKACTAV9WEVGEMMVRZ",
+		AgentDescription:                &protobufs.AgentDescription{},
+		LastEffectiveConfig:             effectiveConfig,
+		LastServerProvidedAllAddonsHash: []byte{4, 5, 6},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvFirstConn) == 1 })
+
+	// Change the description, only the change is sent.
+	description := &protobufs.AgentDescription{
+		IdentifyingAttributes: []*protobufs.KeyValue{
+			{
+				Key:   "service.name",
+				Value: &protobufs.AnyValue{Value: &protobufs.AnyValue_StringValue{StringValue: "test"}},
+			},
+		},
+	}
+	assert.NoError(t, client.SetAgentDescription(description))
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvFirstConn) == 2 })
+
+	// Close the connection to make the client reconnect.
+	conn.Load().(*websocket.Conn).Close()
+
+	// The first message on the new connection must carry the full state.
+	eventually(t, func() bool { return rcvAfterReconnect.Load() != nil })
+	msg := rcvAfterReconnect.Load().(*protobufs.AgentToServer)
+	assert.EqualValues(t, settings.InstanceUid, msg.InstanceUid)
+	assert.True(t, proto.Equal(description, msg.GetStatusReport().GetAgentDescription()))
+	assert.True(t, proto.Equal(effectiveConfig, msg.GetStatusReport().GetEffectiveConfig()))
+	assert.NotZero(t, msg.GetStatusReport().GetCapabilities())
+	assert.EqualValues(t, settings.LastServerProvidedAllAddonsHash, msg.GetAddonStatuses().GetServerProvidedAllAddonsHash())
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHTTPTransportResendsFullStateAfterFailure(t *testing.T) {
+	// Start a server that fails the second request.
+	srv := internal.StartMockServer(t)
+	var requests int64
+	var rcvRetry atomic.Value
+	srv.OnRequest = func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&requests, 1)
+		if n == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var msg protobufs.AgentToServer
+		assert.NoError(t, proto.Unmarshal(body, &msg))
+		if n == 3 {
+			rcvRetry.Store(&msg)
+		}
+	}
+
+	// Start a client.
+	effectiveConfig := &protobufs.EffectiveConfig{
+		Hash:      []byte{1, 2, 3},
+		ConfigMap: &protobufs.AgentConfigMap{},
+	}
+	settings := StartSettings{
+		OpAMPServerURL:      "http://" + srv.Endpoint,
+		AgentDescription:    &protobufs.AgentDescription{},
+		LastEffectiveConfig: effectiveConfig,
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&requests) == 1 })
+
+	// Change the description. The request fails and is retried.
+	description := &protobufs.AgentDescription{
+		NonIdentifyingAttributes: []*protobufs.KeyValue{
+			{
+				Key:   "os.family",
+				Value: &protobufs.AnyValue{Value: &protobufs.AnyValue_StringValue{StringValue: "linux"}},
+			},
+		},
+	}
+	assert.NoError(t, client.SetAgentDescription(description))
+
+	// The retry must carry the full state, not only the change.
+	eventually(t, func() bool { return rcvRetry.Load() != nil })
+	msg := rcvRetry.Load().(*protobufs.AgentToServer)
+	assert.True(t, proto.Equal(description, msg.GetStatusReport().GetAgentDescription()))
+	assert.True(t, proto.Equal(effectiveConfig, msg.GetStatusReport().GetEffectiveConfig()))
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+// connectRecorder records the server URLs passed to the connection callbacks.
+type connectRecorder struct {
+	mutex     sync.Mutex
+	connected []string
+	failed    []string
+}
+
+func (r *connectRecorder) callbacks() CallbacksStruct {
+	return CallbacksStruct{
+		OnConnectFunc: func(serverURL string) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.connected = append(r.connected, serverURL)
+		},
+		OnConnectFailedFunc: func(serverURL string, err error) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.failed = append(r.failed, serverURL)
+		},
+	}
+}
+
+func (r *connectRecorder) connectedURLs() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.connected...)
+}
+
+func (r *connectRecorder) failedURLs() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.failed...)
+}
+
+func TestFailoverOnConnectFailure(t *testing.T) {
+	// Start a failover server only.
+	srv := internal.StartMockServer(t)
+
+	// Start a client.
+	var recorder connectRecorder
+	settings := createNoServerSettings()
+	settings.FailoverServerURLs = []string{"ws://" + srv.Endpoint}
+	settings.Callbacks = recorder.callbacks()
+	client := startClient(t, settings)
+
+	// The client must connect to the failover server.
+	eventually(t, func() bool { return len(recorder.connectedURLs()) == 1 })
+	assert.EqualValues(t, []string{"ws://" + srv.Endpoint}, recorder.connectedURLs())
+	assert.EqualValues(t, []string{settings.OpAMPServerURL}, recorder.failedURLs())
+	assert.EqualValues(t, "ws://"+srv.Endpoint, client.Status().ServerURL)
+
+	// Shutdown the server.
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+// unavailableResponse returns a response that tells the agent that the server
+// is unavailable and to retry after an hour.
+func unavailableResponse(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+	return &protobufs.ServerToAgent{
+		InstanceUid: msg.InstanceUid,
+		ErrorResponse: &protobufs.ServerErrorResponse{
+			Type: protobufs.ServerErrorResponse_Unavailable,
+			Details: &protobufs.ServerErrorResponse_RetryInfo{
+				RetryInfo: &protobufs.RetryInfo{
+					RetryAfterNanoseconds: uint64(time.Hour),
+				},
+			},
+		},
+	}
+}
+
+func TestFailoverOnServerUnavailable(t *testing.T) {
+	// Start a primary server that is always unavailable and a failover server.
+	primary := internal.StartMockServer(t)
+	primary.OnMessage = unavailableResponse
+	srv := internal.StartMockServer(t)
+	var rcvMsg int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		atomic.AddInt64(&rcvMsg, 1)
+		return nil
+	}
+
+	// Start a client.
+	var recorder connectRecorder
+	settings := StartSettings{
+		OpAMPServerURL:     "ws://" + primary.Endpoint,
+		FailoverServerURLs: []string{"ws://" + srv.Endpoint},
+		AgentDescription:   &protobufs.AgentDescription{},
+		Callbacks:          recorder.callbacks(),
+	}
+	client := startClient(t, settings)
+
+	// The client must go to the failover server without waiting for the time
+	// requested by the primary server.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvMsg) == 1 })
+	assert.EqualValues(
+		t, []string{settings.OpAMPServerURL, "ws://" + srv.Endpoint}, recorder.connectedURLs(),
+	)
+
+	// Shutdown the servers.
+	primary.Close()
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestFailoverResetsServerCapabilities(t *testing.T) {
+	for _, scheme := range []string{"ws", "http"} {
+		t.Run(scheme, func(t *testing.T) {
+			// Start a primary server that does not accept effective config
+			// and is always unavailable, and a failover server that does not
+			// advertise its capabilities.
+			primary := internal.StartMockServer(t)
+			primary.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+				response := unavailableResponse(msg)
+				response.Capabilities = protobufs.ServerCapabilities_AcceptsStatus
+				return response
+			}
+			srv := internal.StartMockServer(t)
+			var rcvEffectiveConfig atomic.Value
+			srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+				if effectiveConfig := msg.GetStatusReport().GetEffectiveConfig(); effectiveConfig != nil {
+					rcvEffectiveConfig.Store(effectiveConfig)
+				}
+				return nil
+			}
+
+			// Start a client.
+			effectiveConfig := &protobufs.EffectiveConfig{Hash: []byte{1}}
+			settings := StartSettings{
+				OpAMPServerURL:      scheme + "://" + primary.Endpoint,
+				FailoverServerURLs:  []string{scheme + "://" + srv.Endpoint},
+				AgentDescription:    &protobufs.AgentDescription{},
+				Capabilities:        protobufs.AgentCapabilities_ReportsEffectiveConfig,
+				LastEffectiveConfig: effectiveConfig,
+			}
+			client := startClient(t, settings)
+
+			// The capabilities of the primary server must not apply to the
+			// failover server, so the effective config must be sent to it.
+			eventually(t, func() bool { return rcvEffectiveConfig.Load() != nil })
+			assert.True(t, proto.Equal(effectiveConfig, rcvEffectiveConfig.Load().(*protobufs.EffectiveConfig)))
+			assert.EqualValues(
+				t, protobufs.ServerCapabilities_UnspecifiedServerCapability, client.Capabilities().Server,
+			)
+
+			// Shutdown the servers.
+			primary.Close()
+			srv.Close()
+
+			// Shutdown the client.
+			err := client.Stop(context.Background())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFailback(t *testing.T) {
+	// Start a primary server that is unavailable only for the first message
+	// and a failover server.
+	primary := internal.StartMockServer(t)
+	var primaryMsg int64
+	primary.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if atomic.AddInt64(&primaryMsg, 1) == 1 {
+			return unavailableResponse(msg)
+		}
+		return nil
+	}
+	srv := internal.StartMockServer(t)
+
+	// Start a client.
+	var recorder connectRecorder
+	settings := StartSettings{
+		OpAMPServerURL:     "ws://" + primary.Endpoint,
+		FailoverServerURLs: []string{"ws://" + srv.Endpoint},
+		FailbackAfter:      100 * time.Millisecond,
+		AgentDescription:   &protobufs.AgentDescription{},
+		Callbacks:          recorder.callbacks(),
+	}
+	client := startClient(t, settings)
+
+	// The client must fail over and go back to the primary server after the
+	// cooldown. The full state must be sent to the primary server again.
+	eventually(t, func() bool { return atomic.LoadInt64(&primaryMsg) == 2 })
+	assert.EqualValues(
+		t,
+		[]string{settings.OpAMPServerURL, "ws://" + srv.Endpoint, settings.OpAMPServerURL},
+		recorder.connectedURLs(),
+	)
+	assert.EqualValues(t, settings.OpAMPServerURL, client.Status().ServerURL)
+
+	// Shutdown the servers.
+	primary.Close()
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHTTPTransportFailover(t *testing.T) {
+	// Start a primary server that is always unavailable and a failover server.
+	primary := internal.StartMockServer(t)
+	primary.OnRequest = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(internal.RetryAfterHTTPHeader, "3600")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	srv := internal.StartMockServer(t)
+	var rcvStatus int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if msg.GetStatusReport().GetAgentDescription() != nil {
+			atomic.AddInt64(&rcvStatus, 1)
+		}
+		return nil
+	}
+
+	// Start a client.
+	var recorder connectRecorder
+	settings := StartSettings{
+		OpAMPServerURL:     "http://" + primary.Endpoint,
+		FailoverServerURLs: []string{"http://" + srv.Endpoint},
+		AgentDescription:   &protobufs.AgentDescription{},
+		Callbacks:          recorder.callbacks(),
+	}
+	client := startClient(t, settings)
+
+	// The full state must be sent to the failover server.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+	eventually(t, func() bool { return len(recorder.connectedURLs()) > 0 })
+	assert.EqualValues(t, []string{"http://" + srv.Endpoint}, recorder.connectedURLs())
+	assert.EqualValues(t, []string{settings.OpAMPServerURL}, recorder.failedURLs())
+
+	// Shutdown the servers.
+	primary.Close()
+	srv.Close()
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHTTPTransportFailoverClosesIdleConnections(t *testing.T) {
+	// Start a primary server that is always unavailable and keeps the
+	// connections alive, and a failover server.
+	var primaryConns int64
+	primary := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg protobufs.AgentToServer
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.NoError(t, proto.Unmarshal(body, &msg))
+		response, err := proto.Marshal(unavailableResponse(&msg))
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", internal.ContentTypeProtobuf)
+		w.Write(response)
+	}))
+	primary.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt64(&primaryConns, 1)
+		case http.StateClosed:
+			atomic.AddInt64(&primaryConns, -1)
+		}
+	}
+	primary.Start()
+	srv := internal.StartMockServer(t)
+	var rcvMsg int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		atomic.AddInt64(&rcvMsg, 1)
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:     primary.URL,
+		FailoverServerURLs: []string{"http://" + srv.Endpoint},
+		AgentDescription:   &protobufs.AgentDescription{},
+	}
+	client := startClient(t, settings)
+
+	// After failing over the idle connection to the primary server must be
+	// closed.
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvMsg) > 0 })
+	eventually(t, func() bool { return atomic.LoadInt64(&primaryConns) == 0 })
+
+	// Shutdown the client.
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+
+	// Shutdown the servers.
+	primary.Close()
+	srv.Close()
+}
+
+func TestShuffleFailoverServerURLs(t *testing.T) {
+	settings := StartSettings{
+		OpAMPServerURL:            "ws://primary",
+		FailoverServerURLs:        []string{"ws://a", "ws://b", "ws://c", "ws://d"},
+		ShuffleFailoverServerURLs: true,
+	}
+	connSettings, err := newConnectionSettings(&settings)
+	assert.NoError(t, err)
+
+	// The primary endpoint must stay first and be active.
+	var endpoints []string
+	for _, u := range connSettings.endpoints {
+		endpoints = append(endpoints, u.String())
+	}
+	assert.EqualValues(t, "ws://primary", endpoints[0])
+	assert.EqualValues(t, "ws://primary", connSettings.url.String())
+	assert.ElementsMatch(t, settings.FailoverServerURLs, endpoints[1:])
+	assert.EqualValues(t, []string{"ws://a", "ws://b", "ws://c", "ws://d"}, settings.FailoverServerURLs)
+}
+
+// tokenServer is an OAuth2 token endpoint that issues a new token on each
+// request.
+type tokenServer struct {
+	srv *httptest.Server
+
+	// The number of tokens issued so far.
+	issued int64
+}
+
+func startTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	ts := &tokenServer{}
+	ts.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.EqualValues(t, "agent%3A1", id)
+		assert.EqualValues(t, "secret", secret)
+		assert.NoError(t, r.ParseForm())
+		assert.EqualValues(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.EqualValues(t, "opamp read", r.PostForm.Get("scope"))
+		assert.EqualValues(t, "server", r.PostForm.Get("audience"))
+
+		n := atomic.AddInt64(&ts.issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+	}))
+	return ts
+}
+
+func (ts *tokenServer) tokenSource() types.TokenSource {
+	return NewClientCredentialsTokenSource(ClientCredentialsConfig{
+		TokenURL:       ts.srv.URL,
+		ClientID:       "agent:1",
+		ClientSecret:   "secret",
+		Scopes:         []string{"opamp", "read"},
+		EndpointParams: map[string][]string{"audience": {"server"}},
+	})
+}
+
+// currentTokenOnly returns a MockServer.Authorize func that accepts only the
+// token last issued by ts.
+func currentTokenOnly(ts *tokenServer) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		return r.Header.Get("Authorization") ==
+			fmt.Sprintf("Bearer token-%d", atomic.LoadInt64(&ts.issued))
+	}
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	ts := startTokenServer(t, 3600)
+	defer ts.srv.Close()
+	source := ts.tokenSource()
+
+	// The token must be cached.
+	token, err := source.Token(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Bearer token-1", token.AuthorizationHeader())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expiry, time.Minute)
+	token, err = source.Token(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, "token-1", token.AccessToken)
+	assert.EqualValues(t, 1, atomic.LoadInt64(&ts.issued))
+
+	// A new token must be obtained after invalidation.
+	source.Invalidate()
+	token, err = source.Token(context.Background())
+	assert.NoError(t, err)
+	assert.EqualValues(t, "token-2", token.AccessToken)
+
+	// Tokens that are about to expire must not be used.
+	expiring := startTokenServer(t, 1)
+	defer expiring.srv.Close()
+	source = expiring.tokenSource()
+	for i := 1; i <= 2; i++ {
+		token, err = source.Token(context.Background())
+		assert.NoError(t, err)
+		assert.EqualValues(t, fmt.Sprintf("token-%d", i), token.AccessToken)
+	}
+}
+
+func TestClientCredentialsTokenSourceError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":"invalid_client","error_description":"unknown client"}`)
+	}))
+	defer srv.Close()
+
+	source := NewClientCredentialsTokenSource(ClientCredentialsConfig{TokenURL: srv.URL})
+	_, err := source.Token(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_client")
+	assert.Contains(t, err.Error(), "unknown client")
+}
+
+func TestTokenRefreshOnUnauthorized(t *testing.T) {
+	ts := startTokenServer(t, 3600)
+	defer ts.srv.Close()
+
+	// Start a server that accepts only the last issued token.
+	srv := internal.StartMockServer(t)
+	srv.Authorize = currentTokenOnly(ts)
+	var conn atomic.Value
+	srv.OnConnect = func(r *http.Request, c *websocket.Conn) {
+		conn.Store(c)
+	}
+
+	// Start a client that already has a token that the server no longer accepts.
+	source := ts.tokenSource()
+	_, err := source.Token(context.Background())
+	assert.NoError(t, err)
+	atomic.AddInt64(&ts.issued, 1)
+
+	settings := StartSettings{
+		OpAMPServerURL:   "ws://" + srv.Endpoint,
+		TokenSource:      source,
+		AgentDescription: &protobufs.AgentDescription{},
+		// Only an immediate retry can connect in time.
+		Backoff: BackoffSettings{InitialInterval: time.Hour},
+	}
+	client := startClient(t, settings)
+
+	// The client must obtain a new token and connect with it.
+	eventually(t, func() bool { return conn.Load() != nil })
+	assert.EqualValues(t, 3, atomic.LoadInt64(&ts.issued))
+
+	// Shutdown the server and the client.
+	srv.Close()
+	client.Stop(context.Background())
+}
+
+func TestTokenRefreshOnUnauthorizedHTTP(t *testing.T) {
+	ts := startTokenServer(t, 3600)
+	defer ts.srv.Close()
+
+	// Start a server that accepts only the last issued token.
+	srv := internal.StartMockServer(t)
+	srv.Authorize = currentTokenOnly(ts)
+	var rcvMessages int64
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		atomic.AddInt64(&rcvMessages, 1)
+		return nil
+	}
+
+	// Start a client.
+	settings := StartSettings{
+		OpAMPServerURL:      "http://" + srv.Endpoint,
+		TokenSource:         ts.tokenSource(),
+		AgentDescription:    &protobufs.AgentDescription{},
+		HTTPPollingInterval: 10 * time.Millisecond,
+		Backoff:             BackoffSettings{InitialInterval: time.Hour},
+	}
+	client := startClient(t, settings)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvMessages) > 0 })
+
+	// Revoke the token. The client must obtain a new one and retry immediately.
+	atomic.AddInt64(&ts.issued, 1)
+	received := atomic.LoadInt64(&rcvMessages)
+	eventually(t, func() bool { return atomic.LoadInt64(&rcvMessages) > received+1 })
+	assert.EqualValues(t, 3, atomic.LoadInt64(&ts.issued))
+
+	// Shutdown the server and the client.
+	srv.Close()
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestHeaderProvider(t *testing.T) {
+	// Start a server that accepts the second credentials only.
+	srv := internal.StartMockServer(t)
+	srv.Authorize = func(r *http.Request) bool {
+		return r.Header.Get("X-Credentials") == "2"
+	}
+	var conn atomic.Value
+	srv.OnConnect = func(r *http.Request, c *websocket.Conn) {
+		assert.EqualValues(t, "Bearer 12345678", r.Header.Get("Authorization"))
+		conn.Store(c)
+	}
+
+	// Start a client.
+	var calls int64
+	settings := StartSettings{
+		OpAMPServerURL:      "ws://" + srv.Endpoint,
+		AuthorizationHeader: "Bearer 12345678",
+		HeaderProvider: func(ctx context.Context) (http.Header, error) {
+			n := atomic.AddInt64(&calls, 1)
+			return http.Header{"X-Credentials": []string{fmt.Sprint(n)}}, nil
+		},
+		AgentDescription: &protobufs.AgentDescription{},
+		Backoff:          BackoffSettings{InitialInterval: time.Hour},
+	}
+	client := startClient(t, settings)
+
+	// The headers must be obtained again after the server rejected them.
+	eventually(t, func() bool { return conn.Load() != nil })
+	assert.EqualValues(t, 2, atomic.LoadInt64(&calls))
+
+	// Shutdown the server and the client.
+	srv.Close()
+	client.Stop(context.Background())
+}
+
+func TestConnectUnixSocket(t *testing.T) {
+	for _, transport := range []Transport{TransportWebSocket, TransportHTTP} {
+		// Start a server on a Unix domain socket.
+		srv := internal.StartUnixMockServer(t, filepath.Join(t.TempDir(), "opamp.sock"))
+		var rcvPath atomic.Value
+		var rcvStatus int64
+		srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+			if msg.GetStatusReport().GetAgentDescription() != nil {
+				atomic.AddInt64(&rcvStatus, 1)
+			}
+			return nil
+		}
+		srv.Authorize = func(r *http.Request) bool {
+			rcvPath.Store(r.URL.Path)
+			return true
+		}
+
+		// Start a client.
+		var recorder connectRecorder
+		settings := StartSettings{
+			OpAMPServerURL:   srv.Endpoint,
+			Transport:        transport,
+			AgentDescription: &protobufs.AgentDescription{},
+			Callbacks:        recorder.callbacks(),
+			// Must not be used for Unix domain sockets.
+			ProxyURL: "http://127.0.0.1:1",
+		}
+		client := startClient(t, settings)
+
+		// The client must connect to the socket and request the default path.
+		eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) == 1 })
+		eventually(t, func() bool { return len(recorder.connectedURLs()) > 0 })
+		assert.EqualValues(t, []string{srv.Endpoint}, recorder.connectedURLs())
+		assert.EqualValues(t, "/v1/opamp", rcvPath.Load())
+
+		// Shutdown the server and the client.
+		srv.Close()
+		err := client.Stop(context.Background())
+		assert.NoError(t, err)
+	}
+}
+
+func TestInvalidUnixSocketURL(t *testing.T) {
+	for _, serverURL := range []string{"unix://host/opamp.sock", "unix:opamp.sock"} {
+		client := New(nil)
+		err := client.Start(StartSettings{
+			OpAMPServerURL:   serverURL,
+			InstanceUid:      newInstanceUid(),
+			AgentDescription: &protobufs.AgentDescription{},
+		})
+		assert.Error(t, err)
+	}
+}
+
+func TestNetDialContext(t *testing.T) {
+	for _, transport := range []Transport{TransportWebSocket, TransportHTTP} {
+		// Start a server.
+		srv := internal.StartMockServer(t)
+		var rcvStatus int64
+		srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+			atomic.AddInt64(&rcvStatus, 1)
+			return nil
+		}
+
+		// Start a client that establishes the connections with a custom dialer.
+		var dialed atomic.Value
+		settings := StartSettings{
+			OpAMPServerURL:   "ws://" + srv.Endpoint,
+			Transport:        transport,
+			AgentDescription: &protobufs.AgentDescription{},
+			NetDialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialed.Store(network + " " + addr)
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}
+		client := startClient(t, settings)
+
+		// The connection must be established by the custom dialer.
+		eventually(t, func() bool { return atomic.LoadInt64(&rcvStatus) > 0 })
+		assert.EqualValues(t, "tcp "+srv.Endpoint, dialed.Load())
+
+		// Shutdown the server and the client.
+		srv.Close()
+		err := client.Stop(context.Background())
+		assert.NoError(t, err)
+	}
+}
+
+func TestStartInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings StartSettings
+	}{
+		{
+			name:     "no instance uid",
+			settings: StartSettings{OpAMPServerURL: "ws://127.0.0.1:4320"},
+		},
+		{
+			name:     "unsupported scheme",
+			settings: StartSettings{OpAMPServerURL: "tcp://127.0.0.1:4320", InstanceUid: newInstanceUid()},
+		},
+		{
+			name:     "no scheme",
+			settings: StartSettings{OpAMPServerURL: "localhost", InstanceUid: newInstanceUid()},
+		},
+		{
+			name: "unsupported failover scheme",
+			settings: StartSettings{
+				OpAMPServerURL:     "ws://127.0.0.1:4320",
+				FailoverServerURLs: []string{"ftp://127.0.0.1:4320"},
+				InstanceUid:        newInstanceUid(),
+			},
+		},
+		{
+			name: "tls config with insecure scheme",
+			settings: StartSettings{
+				OpAMPServerURL: "http://127.0.0.1:4320",
+				TLSConfig:      &tls.Config{},
+				InstanceUid:    newInstanceUid(),
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.settings.AgentDescription = &protobufs.AgentDescription{}
+			client := New(nil)
+			err := client.Start(test.settings)
+			assert.Error(t, err)
+
+			// The client must not be started.
+			assert.EqualValues(t, types.StateStopped, client.Status().State)
+			assert.ErrorIs(t, client.Stop(context.Background()), errCannotStopNotStarted)
+		})
+	}
+}
+
+func TestRestart(t *testing.T) {
+	// Start a server.
+	srv := internal.StartMockServer(t)
+	var rcvDescription atomic.Value
+	srv.OnMessage = func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+		if descr := msg.GetStatusReport().GetAgentDescription(); descr != nil {
+			rcvDescription.Store(descr)
+		}
+		return nil
+	}
+
+	newDescription := func(version string) *protobufs.AgentDescription {
+		return &protobufs.AgentDescription{
+			IdentifyingAttributes: []*protobufs.KeyValue{
+				{
+					Key: "service.version",
+					Value: &protobufs.AnyValue{
+						Value: &protobufs.AnyValue_StringValue{StringValue: version},
+					},
+				},
+			},
+		}
+	}
+
+	// Start and stop the client a few times, with different settings.
+	client := New(nil)
+	for _, version := range []string{"1", "2", "3"} {
+		var connected int64
+		settings := StartSettings{
+			OpAMPServerURL:   "ws://" + srv.Endpoint,
+			InstanceUid:      newInstanceUid(),
+			AgentDescription: newDescription(version),
+			Callbacks: CallbacksStruct{
+				OnConnectFunc: func(serverURL string) {
+					atomic.StoreInt64(&connected, 1)
+				},
+			},
+		}
+		assert.NoError(t, client.Start(settings))
+		assert.ErrorIs(t, client.Start(settings), errAlreadyStarted)
+
+		// The client must connect and send the new description.
+		eventually(t, func() bool { return atomic.LoadInt64(&connected) == 1 })
+		eventually(t, func() bool {
+			descr, ok := rcvDescription.Load().(*protobufs.AgentDescription)
+			return ok && proto.Equal(settings.AgentDescription, descr)
+		})
+
+		assert.NoError(t, client.Stop(context.Background()))
+		assert.EqualValues(t, types.StateStopped, client.Status().State)
+		assert.ErrorIs(t, client.Stop(context.Background()), errCannotStopNotStarted)
+	}
+
+	// Shutdown the server.
+	srv.Close()
+}
+
+func TestConcurrentStartStop(t *testing.T) {
+	settings := createNoServerSettings()
+	client := New(nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if err := client.Start(settings); err != nil {
+					assert.ErrorIs(t, err, errAlreadyStarted)
+				}
+				client.Capabilities()
+				if err := client.Stop(context.Background()); err != nil {
+					assert.ErrorIs(t, err, errCannotStopNotStarted)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The client must still be usable.
+	assert.NoError(t, client.Start(settings))
+	assert.NoError(t, client.Stop(context.Background()))
+}
+
+// printfRecorder is a printf-style logger that records the messages.
+type printfRecorder struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *printfRecorder) Debugf(format string, v ...interface{}) {
+	l.record(format, v)
+}
+
+func (l *printfRecorder) Errorf(format string, v ...interface{}) {
+	l.record(format, v)
+}
+
+func (l *printfRecorder) record(format string, v []interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *printfRecorder) count() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.messages)
+}
+
+func TestNewWithPrintfLogger(t *testing.T) {
+	// Start a client that cannot connect and logs to a printf-style logger.
+	logger := &printfRecorder{}
+	client := New(logger)
+	assert.NoError(t, client.Start(createNoServerSettings()))
+
+	// The failures must be logged.
+	eventually(t, func() bool { return logger.count() > 0 })
+
+	err := client.Stop(context.Background())
+	assert.NoError(t, err)
+}
@@ -0,0 +1,346 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/client/internal"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// unixScheme is the scheme of the URLs of the OpAMP Servers that listen on a
+// Unix domain socket, e.g. "unix:///run/opamp.sock".
+const unixScheme = "unix"
+
+// unixRequestPath is the URL path requested from the OpAMP Servers that listen
+// on a Unix domain socket. It is the default path of the server.
+const unixRequestPath = "/v1/opamp"
+
+// connectionSettings are the settings that the client uses to connect to the
+// OpAMP Server.
+type connectionSettings struct {
+	// The transport to use. Never TransportAuto.
+	transport Transport
+
+	// The active OpAMP Server URL, one of endpoints.
+	url *url.URL
+
+	// The OpAMP Server URLs that the client fails over between, the primary
+	// one first. The schemes always match the transport.
+	endpoints []*url.URL
+
+	// The index of url in endpoints.
+	endpoint int
+
+	// HTTP request headers to use when connecting to OpAMP Server.
+	requestHeader http.Header
+
+	// Adds the dynamic headers to requestHeader. Nil if there are none.
+	auth *internal.RequestAuth
+
+	// TLS config to use when connecting to OpAMP Server. Nil if TLS is not used.
+	tlsConfig *tls.Config
+
+	// The proxy to connect to OpAMP Server through.
+	proxy internal.ProxySettings
+
+	// Establishes the network connections. Nil if the default dialer is used.
+	netDialContext internal.NetDialContextFunc
+
+	// The client certificate offered by the server that tlsConfig uses. Nil if
+	// no certificate was offered.
+	certificate *protobufs.TLSCertificate
+}
+
+// newConnectionSettings creates connectionSettings from StartSettings.
+func newConnectionSettings(settings *StartSettings) (connectionSettings, error) {
+	var s connectionSettings
+
+	primary, err := parseServerURL(settings.OpAMPServerURL)
+	if err != nil {
+		return s, err
+	}
+	s.endpoints = []*url.URL{primary}
+
+	failoverURLs := settings.FailoverServerURLs
+	if settings.ShuffleFailoverServerURLs {
+		failoverURLs = append([]string(nil), failoverURLs...)
+		random := rand.New(rand.NewSource(time.Now().UnixNano()))
+		random.Shuffle(len(failoverURLs), func(i, j int) {
+			failoverURLs[i], failoverURLs[j] = failoverURLs[j], failoverURLs[i]
+		})
+	}
+	for _, failoverURL := range failoverURLs {
+		u, err := parseServerURL(failoverURL)
+		if err != nil {
+			return s, fmt.Errorf("invalid failover server URL: %w", err)
+		}
+		s.endpoints = append(s.endpoints, u)
+	}
+	s.url = primary
+
+	s.transport = settings.Transport
+	if s.transport == TransportAuto {
+		switch s.url.Scheme {
+		case "http", "https":
+			s.transport = TransportHTTP
+		default:
+			s.transport = TransportWebSocket
+		}
+	}
+
+	s.tlsConfig = settings.TLSConfig
+	s.setURLScheme()
+
+	if settings.AuthorizationHeader != "" {
+		s.requestHeader = http.Header{}
+		s.requestHeader["Authorization"] = []string{settings.AuthorizationHeader}
+	}
+	if settings.TokenSource != nil || settings.HeaderProvider != nil {
+		s.auth = &internal.RequestAuth{
+			TokenSource:    settings.TokenSource,
+			HeaderProvider: settings.HeaderProvider,
+		}
+	}
+
+	if settings.ProxyURL != "" {
+		s.proxy.URL, err = parseProxyURL(settings.ProxyURL)
+		if err != nil {
+			return s, err
+		}
+	}
+	s.proxy.Header = settings.ProxyHeader
+	s.netDialContext = settings.NetDialContext
+
+	return s, nil
+}
+
+// withOffer returns a copy of the settings with the fields that are set in the
+// offer replaced by the offered values. Returns changed=false if the offer does
+// not result in any change.
+func (s connectionSettings) withOffer(
+	offer *protobufs.ConnectionSettings,
+) (newSettings connectionSettings, changed bool, err error) {
+	newSettings = s
+	newSettings.endpoints = make([]*url.URL, len(s.endpoints))
+	for i, endpoint := range s.endpoints {
+		u := *endpoint
+		newSettings.endpoints[i] = &u
+	}
+	newSettings.url = newSettings.endpoints[s.endpoint]
+
+	destinationChanged := offer.Flags&protobufs.ConnectionSettings_DestinationEndpointSet != 0 &&
+		offer.DestinationEndpoint != s.url.String()
+
+	if offer.Headers != nil {
+		header := headerFromProto(offer.Headers)
+		if !reflect.DeepEqual(header, s.requestHeader) {
+			newSettings.requestHeader = header
+			changed = true
+		}
+	}
+
+	if offer.Flags&protobufs.ConnectionSettings_ProxyEndpointSet != 0 &&
+		offer.ProxyEndpoint != proxyEndpoint(s.proxy.URL) {
+		newSettings.proxy.URL = nil
+		if offer.ProxyEndpoint != "" {
+			newSettings.proxy.URL, err = parseProxyURL(offer.ProxyEndpoint)
+			if err != nil {
+				return s, false, err
+			}
+		}
+		changed = true
+	}
+
+	if offer.ProxyHeaders != nil {
+		header := headerFromProto(offer.ProxyHeaders)
+		if !reflect.DeepEqual(header, s.proxy.Header) {
+			newSettings.proxy.Header = header
+			changed = true
+		}
+	}
+
+	if offer.Certificate != nil && !proto.Equal(offer.Certificate, s.certificate) {
+		newSettings.tlsConfig, err = tlsConfigWithCertificate(s.tlsConfig, offer.Certificate)
+		if err != nil {
+			return s, false, err
+		}
+		newSettings.certificate = offer.Certificate
+		changed = true
+	}
+
+	if destinationChanged {
+		// The offered destination replaces the active endpoint. It is checked
+		// against the TLS config that results from the offer.
+		newSettings.url, err = parseSupportedServerURL(offer.DestinationEndpoint, newSettings.tlsConfig)
+		if err != nil {
+			return s, false, fmt.Errorf("invalid destination endpoint: %w", err)
+		}
+		newSettings.endpoints[s.endpoint] = newSettings.url
+		changed = true
+	}
+
+	newSettings.setURLScheme()
+
+	return newSettings, changed, nil
+}
+
+// withEndpoint returns a copy of the settings that uses the endpoint with the
+// specified index in endpoints.
+func (s connectionSettings) withEndpoint(endpoint int) connectionSettings {
+	s.endpoint = endpoint
+	s.url = s.endpoints[endpoint]
+	return s
+}
+
+// nextEndpoint returns the index of the endpoint to fail over to from the
+// active one.
+func (s connectionSettings) nextEndpoint() int {
+	return (s.endpoint + 1) % len(s.endpoints)
+}
+
+// setURLScheme sets the scheme of the URLs to match the transport. A secure
+// scheme is used if the URL already has one or if TLS config is set. Unix
+// domain socket URLs are left as they are.
+func (s *connectionSettings) setURLScheme() {
+	for _, u := range s.endpoints {
+		if u.Scheme == unixScheme {
+			continue
+		}
+		u.Scheme = s.scheme(s.tlsConfig != nil || u.Scheme == "wss" || u.Scheme == "https")
+	}
+}
+
+// scheme returns the URL scheme of the transport.
+func (s connectionSettings) scheme(secure bool) string {
+	switch {
+	case s.transport == TransportHTTP && secure:
+		return "https"
+	case s.transport == TransportHTTP:
+		return "http"
+	case secure:
+		return "wss"
+	default:
+		return "ws"
+	}
+}
+
+// target returns the URL to make the requests to, the function that
+// establishes the network connections for them (nil for the default one) and
+// the proxy to use. If the server listens on a Unix domain socket the
+// connections are made to the socket without a proxy and the requests are
+// made to unixRequestPath.
+func (s connectionSettings) target() (*url.URL, internal.NetDialContextFunc, internal.ProxySettings) {
+	if s.url.Scheme != unixScheme {
+		return s.url, s.netDialContext, s.proxy
+	}
+
+	target := &url.URL{
+		Scheme: s.scheme(s.tlsConfig != nil),
+		Host:   "localhost",
+		Path:   unixRequestPath,
+	}
+	socketPath := s.url.Path
+	netDial := s.netDialContext
+	if netDial == nil {
+		var netDialer net.Dialer
+		netDial = netDialer.DialContext
+	}
+	dialSocket := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return netDial(ctx, "unix", socketPath)
+	}
+	return target, dialSocket, internal.ProxySettings{Disabled: true}
+}
+
+// httpRequestSettings returns the settings to use for HTTP transport requests.
+func (s connectionSettings) httpRequestSettings() internal.HTTPRequestSettings {
+	target, netDial, proxy := s.target()
+	return internal.HTTPRequestSettings{
+		URL:            s.url.String(),
+		RequestURL:     target.String(),
+		Header:         s.requestHeader,
+		TLSConfig:      s.tlsConfig,
+		Proxy:          proxy,
+		NetDialContext: netDial,
+		Auth:           s.auth,
+	}
+}
+
+// parseServerURL parses the URL of the OpAMP Server. Unix domain socket URLs
+// must have an absolute path and no host, e.g. "unix:///run/opamp.sock".
+func parseServerURL(serverURL string) (*url.URL, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == unixScheme && (u.Host != "" || !strings.HasPrefix(u.Path, "/")) {
+		return nil, fmt.Errorf("invalid Unix domain socket URL %q, must be unix:///path/to/socket", serverURL)
+	}
+	return u, nil
+}
+
+// parseSupportedServerURL parses the URL of the OpAMP Server and checks that
+// the client supports its scheme. Only a secure scheme is allowed if TLS config
+// is set.
+func parseSupportedServerURL(serverURL string, tlsConfig *tls.Config) (*url.URL, error) {
+	u, err := parseServerURL(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "wss", "https", unixScheme:
+	case "ws", "http":
+		if tlsConfig != nil {
+			return nil, fmt.Errorf("server URL %q must have a secure scheme when TLSConfig is set", serverURL)
+		}
+	default:
+		return nil, fmt.Errorf(
+			"server URL %q has unsupported scheme %q, must be ws, wss, http, https or unix",
+			serverURL, u.Scheme,
+		)
+	}
+	return u, nil
+}
+
+// headerFromProto converts the headers received from the server to http.Header.
+func headerFromProto(headers *protobufs.Headers) http.Header {
+	header := http.Header{}
+	for _, h := range headers.Headers {
+		header.Add(h.Key, h.Value)
+	}
+	return header
+}
+
+// parseProxyURL parses the URL of the proxy. The "http" scheme is assumed if
+// the URL has no scheme, e.g. "proxy.example.com:3128".
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	if !strings.Contains(proxyURL, "://") {
+		proxyURL = "http://" + proxyURL
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid proxy URL: unsupported scheme %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// proxyEndpoint returns the proxy URL as a string, or "" if nil.
+func proxyEndpoint(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// FileStateStore is a types.StateStore that keeps the state in a file.
+// The file is replaced atomically, so the saved state is never left partially
+// written, even if the Agent crashes while saving.
+type FileStateStore struct {
+	path string
+}
+
+var _ types.StateStore = (*FileStateStore)(nil)
+
+// NewFileStateStore creates a FileStateStore that keeps the state in the file
+// with the specified path. The directory of the file must exist.
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+// fileState is the content of the state file.
+type fileState struct {
+	InstanceUid                     string `json:"instance_uid"`
+	LastRemoteConfigHash            []byte `json:"last_remote_config_hash,omitempty"`
+	LastEffectiveConfig             []byte `json:"last_effective_config,omitempty"`
+	LastConnectionSettingsHash      []byte `json:"last_connection_settings_hash,omitempty"`
+	LastServerProvidedAllAddonsHash []byte `json:"last_server_provided_all_addons_hash,omitempty"`
+}
+
+func (s *FileStateStore) Load() (*types.State, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var saved fileState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("cannot decode state file %s: %w", s.path, err)
+	}
+
+	state := &types.State{
+		InstanceUid:                     saved.InstanceUid,
+		LastRemoteConfigHash:            saved.LastRemoteConfigHash,
+		LastConnectionSettingsHash:      saved.LastConnectionSettingsHash,
+		LastServerProvidedAllAddonsHash: saved.LastServerProvidedAllAddonsHash,
+	}
+	if saved.LastEffectiveConfig != nil {
+		state.LastEffectiveConfig = &protobufs.EffectiveConfig{}
+		if err := proto.Unmarshal(saved.LastEffectiveConfig, state.LastEffectiveConfig); err != nil {
+			return nil, fmt.Errorf("cannot decode effective config in state file %s: %w", s.path, err)
+		}
+	}
+	return state, nil
+}
+
+func (s *FileStateStore) Save(state *types.State) error {
+	saved := fileState{
+		InstanceUid:                     state.InstanceUid,
+		LastRemoteConfigHash:            state.LastRemoteConfigHash,
+		LastConnectionSettingsHash:      state.LastConnectionSettingsHash,
+		LastServerProvidedAllAddonsHash: state.LastServerProvidedAllAddonsHash,
+	}
+	if state.LastEffectiveConfig != nil {
+		var err error
+		saved.LastEffectiveConfig, err = proto.Marshal(state.LastEffectiveConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file in the same directory and then rename it, so
+	// that the state file is replaced atomically.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(s.path))
+}
+
+// syncDir flushes the directory to disk, so that a rename of a file in the
+// directory survives a crash.
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		// Directories cannot be synced on Windows, the rename is durable
+		// without it.
+		return nil
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
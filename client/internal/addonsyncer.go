@@ -0,0 +1,219 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/logging"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+var errAddonSyncFailed = errors.New("failed to sync one or more addons")
+
+// AddonSyncer performs the syncing process of the addons offered by the server
+// in an AddonsAvailable message and reports the progress to the server via
+// AgentAddonStatuses.
+type AddonSyncer struct {
+	logger     logging.Logger
+	available  *protobufs.AddonsAvailable
+	sender     *Sender
+	httpClient *http.Client
+
+	// The statuses of all addons that are reported to the server.
+	statuses *protobufs.AgentAddonStatuses
+}
+
+var _ types.AddonSyncer = (*AddonSyncer)(nil)
+
+// NewAddonSyncer creates a new AddonSyncer for the specified AddonsAvailable
+// message. Addon statuses will be reported to the server using the sender.
+func NewAddonSyncer(
+	logger logging.Logger,
+	available *protobufs.AddonsAvailable,
+	sender *Sender,
+) *AddonSyncer {
+	return &AddonSyncer{
+		logger:     logger,
+		available:  available,
+		sender:     sender,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// Sync performs the syncing process. The localState is used to find out what
+// addons the agent currently has and to store the addons downloaded from the server.
+// Sync blocks until all addons are synced or until the ctx is cancelled.
+func (s *AddonSyncer) Sync(ctx context.Context, localState types.AddonStateProvider) error {
+	localAllAddonsHash, err := localState.AllAddonsHash()
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(localAllAddonsHash, s.available.AllAddonsHash) {
+		// The agent already has all the addons offered by the server.
+		return nil
+	}
+
+	localAddons, err := localState.Addons()
+	if err != nil {
+		return err
+	}
+
+	s.initStatuses(localAllAddonsHash)
+
+	// Install or update the addons that are offered by the server.
+	var syncErr error
+	for name, addon := range s.available.Addons {
+		if err := s.syncAddon(ctx, localState, localAddons, name, addon); err != nil {
+			s.logger.Error("Cannot sync addon", logging.String("addon", name), logging.Err(err))
+			syncErr = errAddonSyncFailed
+		}
+		if ctx.Err() != nil {
+			s.reportStatuses()
+			return ctx.Err()
+		}
+	}
+
+	// Delete the local addons that are no longer offered by the server.
+	for _, name := range localAddons {
+		if _, offered := s.available.Addons[name]; offered {
+			continue
+		}
+		if err := localState.DeleteAddon(name); err != nil {
+			s.logger.Error("Cannot delete addon", logging.String("addon", name), logging.Err(err))
+			syncErr = errAddonSyncFailed
+		}
+	}
+
+	if syncErr == nil {
+		// All addons are synced. Remember the aggregate hash so that we don't
+		// sync again next time the server offers the same addons.
+		syncErr = localState.SetAllAddonsHash(s.available.AllAddonsHash)
+		if syncErr == nil {
+			s.statuses.ServerProvidedAllAddonsHash = s.available.AllAddonsHash
+		}
+	}
+
+	s.reportStatuses()
+
+	return syncErr
+}
+
+// initStatuses marks all offered addons as pending installation and reports
+// the statuses to the server.
+func (s *AddonSyncer) initStatuses(localAllAddonsHash []byte) {
+	s.statuses = &protobufs.AgentAddonStatuses{
+		Addons:                      map[string]*protobufs.AgentAddonStatus{},
+		ServerProvidedAllAddonsHash: localAllAddonsHash,
+	}
+	for name, addon := range s.available.Addons {
+		s.statuses.Addons[name] = &protobufs.AgentAddonStatus{
+			Name:              name,
+			ServerOfferedHash: addon.Hash,
+			Status:            protobufs.AgentAddonStatus_InstallPending,
+		}
+	}
+	s.reportStatuses()
+}
+
+// syncAddon makes sure the local addon with the specified name matches the
+// addon offered by the server.
+func (s *AddonSyncer) syncAddon(
+	ctx context.Context,
+	localState types.AddonStateProvider,
+	localAddons []string,
+	name string,
+	addon *protobufs.AddonAvailable,
+) error {
+	status := s.statuses.Addons[name]
+
+	exists := false
+	for _, localName := range localAddons {
+		if localName == name {
+			exists = true
+			break
+		}
+	}
+
+	if exists {
+		localHash, err := localState.AddonHash(name)
+		if err != nil {
+			return s.failed(status, err)
+		}
+		status.AgentHasHash = localHash
+		if bytes.Equal(localHash, addon.Hash) {
+			// The agent already has this exact addon.
+			status.Status = protobufs.AgentAddonStatus_Installed
+			return nil
+		}
+	} else {
+		if err := localState.CreateAddon(name); err != nil {
+			return s.failed(status, err)
+		}
+	}
+
+	status.Status = protobufs.AgentAddonStatus_Installing
+	s.reportStatuses()
+
+	if addon.File != nil {
+		if err := s.syncFile(ctx, localState, name, addon.File); err != nil {
+			return s.failed(status, err)
+		}
+	}
+
+	if err := localState.SetAddonHash(name, addon.Hash); err != nil {
+		return s.failed(status, err)
+	}
+
+	status.AgentHasHash = addon.Hash
+	status.Status = protobufs.AgentAddonStatus_Installed
+	s.reportStatuses()
+
+	return nil
+}
+
+// syncFile downloads the addon file if its content differs from what the agent has.
+func (s *AddonSyncer) syncFile(
+	ctx context.Context,
+	localState types.AddonStateProvider,
+	name string,
+	file *protobufs.DownloadableFile,
+) error {
+	localContentHash, err := localState.FileContentHash(name)
+	if err != nil {
+		return err
+	}
+	if len(localContentHash) != 0 && bytes.Equal(localContentHash, file.ContentHash) {
+		// The content is the same, no need to download.
+		return nil
+	}
+
+	content, err := downloadFile(ctx, s.httpClient, file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return localState.UpdateContent(ctx, name, content, file.ContentHash)
+}
+
+func (s *AddonSyncer) failed(status *protobufs.AgentAddonStatus, err error) error {
+	status.Status = protobufs.AgentAddonStatus_InstallFailed
+	status.ErrorMessage = err.Error()
+	s.reportStatuses()
+	return err
+}
+
+// reportStatuses schedules sending of the current addon statuses to the server.
+func (s *AddonSyncer) reportStatuses() {
+	statuses := proto.Clone(s.statuses).(*protobufs.AgentAddonStatuses)
+	s.sender.UpdateNextMessage(func(msg *protobufs.AgentToServer) {
+		msg.AddonStatuses = statuses
+	})
+	s.sender.ScheduleSend()
+}
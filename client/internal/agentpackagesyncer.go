@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/logging"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+var errNoPackageFile = errors.New("agent package has no downloadable file")
+
+// AgentPackageSyncer performs the syncing process of the agent package offered
+// by the server in an AgentPackageAvailable message and reports the progress to
+// the server via AgentInstallStatus.
+type AgentPackageSyncer struct {
+	logger     logging.Logger
+	available  *protobufs.AgentPackageAvailable
+	sender     *Sender
+	httpClient *http.Client
+}
+
+var _ types.AgentPackageSyncer = (*AgentPackageSyncer)(nil)
+
+// NewAgentPackageSyncer creates a new AgentPackageSyncer for the specified
+// AgentPackageAvailable message. The install status will be reported to the
+// server using the sender.
+func NewAgentPackageSyncer(
+	logger logging.Logger,
+	available *protobufs.AgentPackageAvailable,
+	sender *Sender,
+) *AgentPackageSyncer {
+	return &AgentPackageSyncer{
+		logger:     logger,
+		available:  available,
+		sender:     sender,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// Sync performs the syncing process. See types.AgentPackageSyncer for details.
+func (s *AgentPackageSyncer) Sync(ctx context.Context, localState types.AgentPackageStateProvider) error {
+	if s.available.File == nil {
+		s.reportStatus(protobufs.AgentInstallStatus_InstallFailed, errNoPackageFile)
+		return errNoPackageFile
+	}
+
+	version, contentHash, err := localState.PackageInfo()
+	if err != nil {
+		s.reportStatus(protobufs.AgentInstallStatus_InstallFailed, err)
+		return err
+	}
+
+	if version == s.available.Version && bytes.Equal(contentHash, s.available.File.ContentHash) {
+		// The agent already has this exact package.
+		s.reportStatus(protobufs.AgentInstallStatus_Installed, nil)
+		return nil
+	}
+
+	s.reportStatus(protobufs.AgentInstallStatus_Installing, nil)
+
+	err = s.syncContent(ctx, localState)
+	switch {
+	case err == nil:
+		s.reportStatus(protobufs.AgentInstallStatus_Installed, nil)
+	case errors.Is(err, os.ErrPermission):
+		s.reportStatus(protobufs.AgentInstallStatus_InstallNoPermission, err)
+	default:
+		s.reportStatus(protobufs.AgentInstallStatus_InstallFailed, err)
+	}
+
+	return err
+}
+
+// syncContent downloads the package file and streams it to the localState.
+func (s *AgentPackageSyncer) syncContent(ctx context.Context, localState types.AgentPackageStateProvider) error {
+	content, err := downloadFile(ctx, s.httpClient, s.available.File)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return localState.UpdateContent(ctx, content, s.available.File.ContentHash, s.available.Version)
+}
+
+// reportStatus schedules sending of the install status to the server.
+func (s *AgentPackageSyncer) reportStatus(status protobufs.AgentInstallStatus_Status, err error) {
+	installStatus := &protobufs.AgentInstallStatus{
+		ServerOfferedVersion: s.available.Version,
+		ServerOfferedHash:    s.available.File.GetContentHash(),
+		Status:               status,
+	}
+	if err != nil {
+		installStatus.ErrorMessage = err.Error()
+	}
+
+	s.sender.UpdateNextMessage(func(msg *protobufs.AgentToServer) {
+		msg.AgentInstallStatus = installStatus
+	})
+	s.sender.ScheduleSend()
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// CallbackKind identifies a kind of callback for the purpose of dispatching.
+// Callbacks of the same kind never run concurrently.
+type CallbackKind int
+
+const (
+	RemoteConfigCallback CallbackKind = iota
+	ConnectionSettingsCallback
+	AddonsAvailableCallback
+	AgentPackageAvailableCallback
+
+	callbackKindCount
+)
+
+// CallbackFunc is a callback invocation queued in the CallbackDispatcher.
+// The ctx is cancelled when the dispatcher is stopped.
+type CallbackFunc func(ctx context.Context)
+
+// CallbackDispatcher runs the callbacks asynchronously, so that slow callbacks
+// do not block receiving of the messages from the server.
+//
+// Only one callback of each kind runs at any time. While a callback is running
+// only the most recently dispatched callback of the same kind is kept pending
+// and the older pending one is discarded. The pending callback is run once the
+// running one returns.
+type CallbackDispatcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex   sync.Mutex
+	stopped bool
+	queues  [callbackKindCount]callbackQueue
+
+	// Counts the goroutines that run the callbacks.
+	running sync.WaitGroup
+}
+
+type callbackQueue struct {
+	// The callback to run next. Nil if there is nothing pending.
+	pending CallbackFunc
+	// True if a goroutine is running the callbacks of this kind.
+	running bool
+}
+
+func NewCallbackDispatcher() *CallbackDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CallbackDispatcher{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Dispatch queues f to run after the currently running callback of the same
+// kind returns, replacing the callback of that kind that is pending, if any.
+// Does nothing if the dispatcher is stopped.
+func (d *CallbackDispatcher) Dispatch(kind CallbackKind, f CallbackFunc) {
+	d.dispatch(kind, f, true)
+}
+
+// DispatchIfNonePending is the same as Dispatch, except that f is discarded
+// if a callback of the same kind is already pending.
+func (d *CallbackDispatcher) DispatchIfNonePending(kind CallbackKind, f CallbackFunc) {
+	d.dispatch(kind, f, false)
+}
+
+func (d *CallbackDispatcher) dispatch(kind CallbackKind, f CallbackFunc, replace bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	q := &d.queues[kind]
+	if q.pending != nil && !replace {
+		return
+	}
+	q.pending = f
+
+	if !q.running {
+		q.running = true
+		d.running.Add(1)
+		go d.run(q)
+	}
+}
+
+// run runs the pending callbacks of one kind until there are none left.
+func (d *CallbackDispatcher) run(q *callbackQueue) {
+	defer d.running.Done()
+
+	for {
+		d.mutex.Lock()
+		f := q.pending
+		q.pending = nil
+		if f == nil || d.stopped {
+			q.running = false
+			d.mutex.Unlock()
+			return
+		}
+		d.mutex.Unlock()
+
+		f(d.ctx)
+	}
+}
+
+// Stop discards the pending callbacks, cancels the context of the running
+// callbacks and waits until they return. Returns ctx.Err() if the ctx is done
+// before that. No callbacks are run after Stop is called.
+func (d *CallbackDispatcher) Stop(ctx context.Context) error {
+	d.mutex.Lock()
+	d.stopped = true
+	for i := range d.queues {
+		d.queues[i].pending = nil
+	}
+	d.mutex.Unlock()
+
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+var errContentHashMismatch = errors.New("downloaded content does not match SHA-256 content hash")
+
+// downloadFile begins downloading the specified file using HTTP GET request.
+// The returned ReadCloser reads the content of the file and verifies that the
+// content matches the content_hash of the file (if the hash is specified). The
+// content_hash must be the SHA-256 hash of the content.
+// If the content does not match the hash then reading returns an error instead
+// of io.EOF when the end of the content is reached.
+// The caller must close the returned ReadCloser.
+func downloadFile(
+	ctx context.Context, httpClient *http.Client, file *protobufs.DownloadableFile,
+) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.DownloadUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot download %s: %w", file.DownloadUrl, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cannot download %s: server responded with status %v", file.DownloadUrl, resp.Status)
+	}
+
+	if len(file.ContentHash) == 0 {
+		// No hash to verify against.
+		return resp.Body, nil
+	}
+
+	return &hashVerifyingReader{
+		body:         resp.Body,
+		hash:         sha256.New(),
+		expectedHash: file.ContentHash,
+	}, nil
+}
+
+// hashVerifyingReader calculates the hash of the content read from body and
+// compares it to expectedHash when the end of the content is reached.
+type hashVerifyingReader struct {
+	body         io.ReadCloser
+	hash         hash.Hash
+	expectedHash []byte
+}
+
+func (r *hashVerifyingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(r.hash.Sum(nil), r.expectedHash) {
+		return n, errContentHashMismatch
+	}
+	return n, err
+}
+
+func (r *hashVerifyingReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,373 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/logging"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// ContentTypeProtobuf is the Content-Type of the HTTP requests and responses
+// that carry OpAMP messages.
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// DefaultHTTPPollingInterval is the interval at which HTTPSender polls the
+// server when there are no messages to send, unless a different interval is
+// specified.
+const DefaultHTTPPollingInterval = 30 * time.Second
+
+var (
+	errUnexpectedHTTPStatus = errors.New("unexpected HTTP response status")
+	errUnauthorized         = errors.New("server rejected the credentials")
+)
+
+// HTTPRequestSettings are the settings that HTTPSender uses to make requests
+// to the OpAMP Server.
+type HTTPRequestSettings struct {
+	// The URL of the OpAMP Server, reported to the callbacks.
+	URL string
+
+	// The URL to make the requests to. Differs from URL if the server listens
+	// on a Unix domain socket.
+	RequestURL string
+
+	Header    http.Header
+	TLSConfig *tls.Config
+	Proxy     ProxySettings
+
+	// Establishes the network connections. If nil the default dialer is used.
+	NetDialContext NetDialContextFunc
+
+	// Adds the dynamic headers to each request. Optional.
+	Auth *RequestAuth
+}
+
+// HTTPSender implements the client's sending portion of OpAMP protocol over
+// plain HTTP. Each message is sent as the body of a POST request and the
+// ServerToAgent message received in the response is passed to the Receiver.
+// When there is nothing to send the server is polled periodically.
+type HTTPSender struct {
+	logger    logging.Logger
+	callbacks types.Callbacks
+	sender    *Sender
+	status    *StatusTracker
+	backoff   *RetryBackoff
+
+	// The interval at which to poll the server when there is nothing to send.
+	pollingInterval time.Duration
+
+	// The settings and the client to use for the requests.
+	requestSettings HTTPRequestSettings
+	httpClient      *http.Client
+	settingsMutex   sync.RWMutex
+
+	// The URL of the server if the last request succeeded, "" otherwise. Used
+	// to call OnConnect and send the full state only when the server becomes
+	// reachable or the client switches to another server.
+	connectedURL string
+
+	// Called after a failed request, before retrying. Returns true if it
+	// switched to another server. Optional.
+	failover func() bool
+}
+
+func NewHTTPSender(
+	logger logging.Logger,
+	callbacks types.Callbacks,
+	sender *Sender,
+	status *StatusTracker,
+	backoff *RetryBackoff,
+	pollingInterval time.Duration,
+) *HTTPSender {
+	if pollingInterval <= 0 {
+		pollingInterval = DefaultHTTPPollingInterval
+	}
+	return &HTTPSender{
+		logger:          logger,
+		callbacks:       callbacks,
+		sender:          sender,
+		status:          status,
+		backoff:         backoff,
+		pollingInterval: pollingInterval,
+	}
+}
+
+// SetRequestSettings sets the settings to use for all subsequent requests.
+// The HTTP client is reused if the settings of the connections do not change.
+// The idle connections of the previous client are closed if the client is
+// replaced or the requests go to another URL.
+func (h *HTTPSender) SetRequestSettings(settings HTTPRequestSettings) {
+	h.settingsMutex.Lock()
+	oldSettings := h.requestSettings
+	oldClient := h.httpClient
+	if oldClient == nil || !sameTransport(oldSettings, settings) {
+		h.httpClient = newHTTPClient(settings)
+	}
+	h.requestSettings = settings
+	newClient := h.httpClient
+	h.settingsMutex.Unlock()
+
+	if oldClient != nil && (oldClient != newClient || oldSettings.RequestURL != settings.RequestURL) {
+		oldClient.CloseIdleConnections()
+	}
+}
+
+// SetFailover sets the function that is called after a request fails, before
+// it is retried. The function may switch to another server by calling
+// SetRequestSettings and must return true if it did. Must be called before Run.
+func (h *HTTPSender) SetFailover(failover func() bool) {
+	h.failover = failover
+}
+
+// Probe verifies that the server can be reached using the specified settings by
+// sending a message that contains only the instance UID. The ServerToAgent
+// message received in the response is not processed.
+func (h *HTTPSender) Probe(ctx context.Context, instanceUid string, settings HTTPRequestSettings) error {
+	msg := &protobufs.AgentToServer{InstanceUid: instanceUid}
+	httpClient := newHTTPClient(settings)
+	defer httpClient.CloseIdleConnections()
+	_, _, err := h.sendRequest(ctx, httpClient, settings, msg)
+	return err
+}
+
+// Run sends the pending messages and polls the server until the ctx is
+// cancelled. The full state of the agent is sent immediately and again after
+// every failed request, since the server that handles the next request may
+// know nothing about the agent.
+func (h *HTTPSender) Run(ctx context.Context, instanceUid string, receiver *Receiver) {
+	for {
+		msgToSend := h.sender.takeNextMessage()
+		if msgToSend == nil {
+			// Nothing is pending, this is a poll.
+			msgToSend = &protobufs.AgentToServer{}
+		}
+		msgToSend.InstanceUid = instanceUid
+
+		if !h.sendUntilSuccess(ctx, msgToSend, receiver) {
+			return
+		}
+
+		// Wait until there is something to send or it is time to poll.
+		timer := time.NewTimer(h.pollingInterval)
+		select {
+		case <-h.sender.hasMessages:
+			timer.Stop()
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// SendDisconnect sends the pending message (if any) together with the
+// AgentDisconnect message. Must not be called while Run is running.
+func (h *HTTPSender) SendDisconnect(ctx context.Context, instanceUid string) error {
+	msgToSend := h.sender.takeNextMessage()
+	if msgToSend == nil {
+		msgToSend = &protobufs.AgentToServer{}
+	}
+	msgToSend.InstanceUid = instanceUid
+	msgToSend.AgentDisconnect = &protobufs.AgentDisconnect{}
+
+	h.settingsMutex.RLock()
+	settings := h.requestSettings
+	httpClient := h.httpClient
+	h.settingsMutex.RUnlock()
+
+	_, _, err := h.sendRequest(ctx, httpClient, settings, msgToSend)
+	if err != nil {
+		// Keep the pending state in case the client is started again.
+		msgToSend.AgentDisconnect = nil
+		h.sender.restoreUnsentMessage(msgToSend)
+	}
+	return err
+}
+
+// sendUntilSuccess sends the message and processes the response, retrying with
+// backoff until the server accepts the message. Returns false if the ctx is
+// cancelled before that.
+func (h *HTTPSender) sendUntilSuccess(
+	ctx context.Context,
+	msg *protobufs.AgentToServer,
+	receiver *Receiver,
+) bool {
+	// Set when the credentials were refreshed after the server rejected them.
+	// They are refreshed at most once between the waits.
+	refreshed := false
+	for {
+		h.settingsMutex.RLock()
+		settings := h.requestSettings
+		httpClient := h.httpClient
+		h.settingsMutex.RUnlock()
+
+		if settings.URL != h.connectedURL {
+			h.status.SetConnecting(settings.URL)
+			// The capabilities of the previous server do not apply, they are
+			// learned again from the response.
+			h.sender.SetServerCapabilities(protobufs.ServerCapabilities_UnspecifiedServerCapability)
+			// The server may know nothing about the agent (e.g. it restarted
+			// or it is a different server), send the full state. It includes
+			// the message.
+			fullState := h.sender.takeFullState()
+			fullState.InstanceUid = msg.InstanceUid
+			msg = fullState
+		}
+
+		response, retryAfter, err := h.sendRequest(ctx, httpClient, settings, msg)
+		if err == nil {
+			h.status.SetConnected(settings.URL)
+			if settings.URL != h.connectedURL {
+				h.connectedURL = settings.URL
+				h.logger.Info("Connected to the server", logging.ServerURL(settings.URL))
+				if h.callbacks != nil {
+					h.callbacks.OnConnect(settings.URL)
+				}
+			}
+			retryAfter = receiver.ProcessReceivedMessage(response)
+			if !retryAfter.Defined {
+				h.backoff.Reset()
+				return true
+			}
+			// The server is unavailable and did not process the message.
+			h.connectedURL = ""
+		} else {
+			if ctx.Err() != nil {
+				h.logger.Debug("Client is stopped, will not try anymore")
+				h.sender.restoreUnsentMessage(msg)
+				return false
+			}
+			if errors.Is(err, errUnauthorized) && !refreshed && settings.Auth.Refresh() {
+				// The token may have expired, retry immediately with a new one.
+				h.logger.Info("Server rejected the credentials, retrying with new ones", logging.ServerURL(settings.URL))
+				refreshed = true
+				continue
+			}
+			h.connectedURL = ""
+			h.status.SetError(err)
+			if h.callbacks != nil {
+				h.callbacks.OnConnectFailed(settings.URL, err)
+			}
+			h.logger.Warn("Request failed, will retry", logging.ServerURL(settings.URL), logging.Err(err))
+		}
+
+		// Try another server, if any. When to retry as instructed by this
+		// server does not apply to the other servers.
+		if h.failover != nil && h.failover() {
+			retryAfter = OptionalDuration{}
+		}
+
+		// Retry again a bit later.
+		interval := h.backoff.NextInterval(retryAfter)
+		h.status.SetBackoff(interval)
+		if err := h.backoff.Wait(ctx, interval); err != nil {
+			h.logger.Debug("Client is stopped, will not try anymore")
+			h.sender.restoreUnsentMessage(msg)
+			return false
+		}
+		refreshed = false
+	}
+}
+
+// sendRequest POSTs the message to the server and returns the message received
+// in the response. If the request fails the returned retryAfter is defined if
+// the server indicated when to retry.
+func (h *HTTPSender) sendRequest(
+	ctx context.Context,
+	httpClient *http.Client,
+	settings HTTPRequestSettings,
+	msg *protobufs.AgentToServer,
+) (response *protobufs.ServerToAgent, retryAfter OptionalDuration, err error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, retryAfter, fmt.Errorf("cannot marshal data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, settings.RequestURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, retryAfter, err
+	}
+	header, err := settings.Auth.Header(ctx, settings.Header)
+	if err != nil {
+		return nil, retryAfter, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", ContentTypeProtobuf)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, retryAfter, err
+	}
+	defer resp.Body.Close()
+	h.status.CountSent(len(data))
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, retryAfter, fmt.Errorf("%w: %s", errUnauthorized, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		h.logger.Warn("Server responded with unexpected status", logging.ServerURL(settings.URL), logging.String("status", resp.Status))
+		return nil, ExtractRetryAfterHeader(resp), fmt.Errorf("%w: %s", errUnexpectedHTTPStatus, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, retryAfter, fmt.Errorf("cannot read response: %w", err)
+	}
+
+	h.status.CountReceived(len(body))
+
+	response = &protobufs.ServerToAgent{}
+	if err := proto.Unmarshal(body, response); err != nil {
+		return nil, retryAfter, fmt.Errorf("cannot decode received message: %w", err)
+	}
+	return response, retryAfter, nil
+}
+
+// sameTransport returns true if the requests made with the settings a and b
+// can use the same HTTP transport. The transports that use a custom dialer are
+// never shared, since the dialers cannot be compared.
+func sameTransport(a, b HTTPRequestSettings) bool {
+	return a.TLSConfig == b.TLSConfig &&
+		a.NetDialContext == nil && b.NetDialContext == nil &&
+		a.Proxy.Disabled == b.Proxy.Disabled &&
+		proxyURL(a.Proxy.URL) == proxyURL(b.Proxy.URL) &&
+		reflect.DeepEqual(a.Proxy.Header, b.Proxy.Header)
+}
+
+func proxyURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+func newHTTPClient(settings HTTPRequestSettings) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = settings.TLSConfig
+	if settings.NetDialContext != nil {
+		transport.DialContext = settings.NetDialContext
+	}
+	switch {
+	case settings.Proxy.Disabled:
+		transport.Proxy = nil
+	case settings.Proxy.URL != nil:
+		transport.Proxy = http.ProxyURL(settings.Proxy.URL)
+	}
+	transport.ProxyConnectHeader = settings.Proxy.Header
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/open-telemetry/opamp-go/internal/testhelpers"
+)
+
+// MockProxy is an HTTP proxy that supports the CONNECT method only.
+type MockProxy struct {
+	Endpoint string
+
+	// OnConnect is called for every CONNECT request before the connection to
+	// the target is established.
+	OnConnect func(r *http.Request)
+
+	srv *httptest.Server
+
+	// The tunneled connections, closed by Close().
+	conns      []net.Conn
+	connsMutex sync.Mutex
+}
+
+func StartMockProxy(t *testing.T) *MockProxy {
+	p := &MockProxy{}
+
+	p.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if p.OnConnect != nil {
+			p.OnConnect(r)
+		}
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		p.connsMutex.Lock()
+		p.conns = append(p.conns, conn, target)
+		p.connsMutex.Unlock()
+
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			conn.Close()
+			target.Close()
+			return
+		}
+
+		// Tunnel the data in both directions until either side closes.
+		go func() {
+			io.Copy(target, conn)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+
+	u, err := url.Parse(p.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Endpoint = u.Host
+
+	testhelpers.WaitForEndpoint(p.Endpoint)
+
+	return p
+}
+
+func (p *MockProxy) Close() {
+	p.srv.Close()
+
+	p.connsMutex.Lock()
+	defer p.connsMutex.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+}
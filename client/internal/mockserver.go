@@ -1,7 +1,10 @@
 package internal
 
 import (
+	"crypto/tls"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -18,6 +21,9 @@ import (
 type MockServer struct {
 	Endpoint  string
 	OnRequest func(w http.ResponseWriter, r *http.Request)
+	// Authorize is called for each request unless OnRequest is set. The
+	// request is rejected with 401 Unauthorized if it returns false.
+	Authorize func(r *http.Request) bool
 	OnConnect func(r *http.Request, conn *websocket.Conn)
 	OnMessage func(msg *protobufs.AgentToServer) *protobufs.ServerToAgent
 	srv       *httptest.Server
@@ -26,6 +32,27 @@ type MockServer struct {
 var upgrader = websocket.Upgrader{}
 
 func StartMockServer(t *testing.T) *MockServer {
+	return startMockServer(t, nil, nil)
+}
+
+// StartTLSMockServer starts a MockServer that accepts TLS connections only,
+// using the specified TLS config.
+func StartTLSMockServer(t *testing.T, tlsConfig *tls.Config) *MockServer {
+	return startMockServer(t, tlsConfig, nil)
+}
+
+// StartUnixMockServer starts a MockServer that listens on the Unix domain
+// socket with the specified path. Endpoint is set to the "unix://" URL of the
+// socket.
+func StartUnixMockServer(t *testing.T, socketPath string) *MockServer {
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startMockServer(t, nil, ln)
+}
+
+func startMockServer(t *testing.T, tlsConfig *tls.Config, listener net.Listener) *MockServer {
 	srv := &MockServer{}
 
 	m := http.NewServeMux()
@@ -36,6 +63,16 @@ func StartMockServer(t *testing.T) *MockServer {
 				return
 			}
 
+			if srv.Authorize != nil && !srv.Authorize(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				srv.handlePlainHTTP(t, w, r)
+				return
+			}
+
 			conn, err := upgrader.Upgrade(w, r, nil)
 			if err != nil {
 				return
@@ -66,7 +103,8 @@ func StartMockServer(t *testing.T) *MockServer {
 						}
 						err = conn.WriteMessage(websocket.BinaryMessage, msgBytes)
 						if err != nil {
-							log.Fatal("cannot send:", err)
+							// The client is gone.
+							return
 						}
 					}
 				}
@@ -74,7 +112,21 @@ func StartMockServer(t *testing.T) *MockServer {
 		},
 	)
 
-	srv.srv = httptest.NewServer(m)
+	switch {
+	case listener != nil:
+		srv.srv = httptest.NewUnstartedServer(m)
+		srv.srv.Listener.Close()
+		srv.srv.Listener = listener
+		srv.srv.Start()
+		srv.Endpoint = "unix://" + listener.Addr().String()
+		return srv
+	case tlsConfig != nil:
+		srv.srv = httptest.NewUnstartedServer(m)
+		srv.srv.TLS = tlsConfig
+		srv.srv.StartTLS()
+	default:
+		srv.srv = httptest.NewServer(m)
+	}
 
 	u, err := url.Parse(srv.srv.URL)
 	if err != nil {
@@ -87,6 +139,37 @@ func StartMockServer(t *testing.T) *MockServer {
 	return srv
 }
 
+// handlePlainHTTP handles a request made by a client that uses HTTP transport.
+func (m *MockServer) handlePlainHTTP(t *testing.T, w http.ResponseWriter, r *http.Request) {
+	assert.EqualValues(t, ContentTypeProtobuf, r.Header.Get("Content-Type"))
+
+	msgBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var dest protobufs.AgentToServer
+	err = proto.Unmarshal(msgBytes, &dest)
+	if err != nil {
+		log.Fatal("cannot decode:", err)
+	}
+
+	response := &protobufs.ServerToAgent{}
+	if m.OnMessage != nil {
+		if r := m.OnMessage(&dest); r != nil {
+			response = r
+		}
+	}
+
+	msgBytes, err = proto.Marshal(response)
+	if err != nil {
+		log.Fatal("cannot encode:", err)
+	}
+	w.Header().Set("Content-Type", ContentTypeProtobuf)
+	w.Write(msgBytes)
+}
+
 func (m *MockServer) Close() {
 	m.srv.Close()
 }
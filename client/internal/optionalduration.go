@@ -0,0 +1,10 @@
+package internal
+
+import "time"
+
+// OptionalDuration is a time.Duration that may be undefined.
+type OptionalDuration struct {
+	Duration time.Duration
+	// true if Duration field is defined.
+	Defined bool
+}
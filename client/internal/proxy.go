@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// ProxySettings are the settings of the HTTP(S) proxy to connect to the OpAMP
+// Server through.
+type ProxySettings struct {
+	// The URL of the proxy. If nil the proxy is selected by the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	URL *url.URL
+
+	// The headers to send to the proxy in the CONNECT request.
+	Header http.Header
+
+	// Disabled disables the proxy, including the one selected by the
+	// environment.
+	Disabled bool
+}
+
+// ProxyURL returns the URL of the proxy to use for the requests to the target,
+// or nil if no proxy should be used.
+func (s ProxySettings) ProxyURL(target *url.URL) (*url.URL, error) {
+	if s.Disabled {
+		return nil, nil
+	}
+	if s.URL != nil {
+		return s.URL, nil
+	}
+	// Use the same logic as the HTTP requests for selecting the proxy from
+	// the environment. The proxy is selected by the HTTP scheme.
+	u := *target
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	return http.ProxyFromEnvironment(&http.Request{URL: &u})
+}
+
+// NetDialContextFunc is the function that establishes network connections.
+type NetDialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ProxyDialContext returns a function that establishes the connections to
+// the addresses through the proxy with the specified URL using the CONNECT
+// method. The connections to the proxy are established using forwardDial.
+func ProxyDialContext(proxyURL *url.URL, header http.Header, forwardDial NetDialContextFunc) NetDialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return proxyDial(ctx, proxyURL, header, forwardDial, network, addr)
+	}
+}
+
+func proxyDial(
+	ctx context.Context,
+	proxyURL *url.URL,
+	header http.Header,
+	forwardDial NetDialContextFunc,
+	network, addr string,
+) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	conn, err := forwardDial(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to proxy: %w", err)
+	}
+
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot connect to proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	connectHeader := header.Clone()
+	if connectHeader == nil {
+		connectHeader = http.Header{}
+	}
+	if user := proxyURL.User; user != nil && connectHeader.Get("Proxy-Authorization") == "" {
+		password, _ := user.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		connectHeader.Set("Proxy-Authorization", "Basic "+credential)
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: connectHeader,
+	}
+
+	// Abort the CONNECT exchange if the ctx is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The server does not send anything until the client speaks, so nothing
+	// is lost when the buffered reader is discarded.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy responded with status %s", resp.Status)
+	}
+	return conn, nil
+}
@@ -2,122 +2,188 @@ package internal
 
 import (
 	"context"
-	"fmt"
-
-	"github.com/gorilla/websocket"
-	"google.golang.org/protobuf/proto"
+	"time"
 
 	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/logging"
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
-// Receiver implements the client's receiving portion of OpAMP protocol.
+// Receiver implements the processing of the messages received from the server.
+// It is shared by all transports. The transport-specific receiving is done by
+// WSReceiver or HTTPSender, which pass the received messages to Receiver.
+// The callbacks are run asynchronously by the dispatcher.
 type Receiver struct {
-	conn      *websocket.Conn
-	logger    types.Logger
-	sender    *Sender
-	callbacks types.Callbacks
+	logger     logging.Logger
+	sender     *Sender
+	callbacks  types.Callbacks
+	dispatcher *CallbackDispatcher
+
+	// Verifies and applies the OpAMP connection settings offered by the server.
+	applyOpampSettings ApplyOpampSettingsFunc
+
+	// Indicates that the full state was resent in response to a BadRequest error
+	// and no successful response was received from the server since then.
+	fullStateResent bool
 }
 
-func NewReceiver(logger types.Logger, callbacks types.Callbacks, conn *websocket.Conn, sender *Sender) *Receiver {
+// ApplyOpampSettingsFunc verifies the offered OpAMP connection settings by
+// connecting to the server using them. If the connection succeeds the function
+// switches the client to the new settings and returns nil. Otherwise it
+// returns an error and the client continues using the current settings.
+type ApplyOpampSettingsFunc func(ctx context.Context, settings *protobufs.ConnectionSettings) error
+
+func NewReceiver(
+	logger logging.Logger,
+	callbacks types.Callbacks,
+	sender *Sender,
+	dispatcher *CallbackDispatcher,
+	applyOpampSettings ApplyOpampSettingsFunc,
+) *Receiver {
 	return &Receiver{
-		conn:      conn,
-		logger:    logger,
-		sender:    sender,
-		callbacks: callbacks,
+		logger:             logger,
+		sender:             sender,
+		callbacks:          callbacks,
+		dispatcher:         dispatcher,
+		applyOpampSettings: applyOpampSettings,
 	}
 }
 
-func (r *Receiver) ReceiverLoop(ctx context.Context) {
-	runContext, cancelFunc := context.WithCancel(ctx)
-
-out:
-	for {
-		var message protobufs.ServerToAgent
-		if err := r.receiveMessage(&message); err != nil {
-			if ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-				r.logger.Errorf("Unexpected error while receiving: %v", err)
-			}
-			break out
-		} else {
-			r.processReceivedMessage(runContext, &message)
-		}
+// ProcessReceivedMessage processes a message received from the server. If the
+// server reports that it is unavailable the returned retryAfter is defined and
+// indicates how long the client should wait before contacting the server again
+// (zero if the server did not specify it).
+//
+// The callbacks are not called by ProcessReceivedMessage, they are dispatched
+// to run asynchronously and report their results to the server when they return.
+func (r *Receiver) ProcessReceivedMessage(msg *protobufs.ServerToAgent) (retryAfter OptionalDuration) {
+	if msg.Capabilities != protobufs.ServerCapabilities_UnspecifiedServerCapability {
+		r.sender.SetServerCapabilities(msg.Capabilities)
 	}
 
-	cancelFunc()
-}
+	if r.callbacks != nil {
+		r.dispatchRemoteConfig(msg.RemoteConfig)
+		r.dispatchConnectionSettings(msg.ConnectionSettings)
+		r.dispatchAddonsAvailable(msg.AddonsAvailable)
+		r.dispatchAgentPackageAvailable(msg.AgentPackageAvailable)
+	}
 
-func (r *Receiver) receiveMessage(msg *protobufs.ServerToAgent) error {
-	_, bytes, err := r.conn.ReadMessage()
-	if err != nil {
-		return err
+	// Resend the data that the server asks for.
+	if msg.Flags&protobufs.ServerToAgent_ReportEffectiveConfig != 0 {
+		r.sender.ScheduleEffectiveConfigSend()
+	}
+	if msg.Flags&protobufs.ServerToAgent_ReportAddonStatus != 0 {
+		r.sender.ScheduleAddonStatusesSend()
 	}
-	err = proto.Unmarshal(bytes, msg)
+
+	err := msg.GetErrorResponse()
 	if err != nil {
-		return fmt.Errorf("cannot decode received message: %w", err)
+		return r.processErrorResponse(err)
 	}
-	return err
+	r.fullStateResent = false
+	return OptionalDuration{Defined: false}
 }
 
-func (r *Receiver) processReceivedMessage(ctx context.Context, msg *protobufs.ServerToAgent) {
-	if r.callbacks != nil {
-		reportStatus := r.rcvRemoteConfig(ctx, msg.RemoteConfig)
-
-		r.rcvConnectionSettings(ctx, msg.ConnectionSettings)
-		r.rcvAddonsAvailable(msg.AddonsAvailable)
-
-		if reportStatus {
+func (r *Receiver) dispatchRemoteConfig(config *protobufs.AgentRemoteConfig) {
+	f := func(ctx context.Context) {
+		if r.rcvRemoteConfig(ctx, config) {
 			r.sender.ScheduleSend()
 		}
 	}
-
-	err := msg.GetErrorResponse()
-	if err != nil {
-		r.processErrorResponse(err)
+	if config == nil {
+		// Unchanged config must not replace a newer config that is pending.
+		r.dispatcher.DispatchIfNonePending(RemoteConfigCallback, f)
+		return
 	}
+	r.dispatcher.Dispatch(RemoteConfigCallback, f)
 }
 
 func (r *Receiver) rcvRemoteConfig(ctx context.Context, config *protobufs.AgentRemoteConfig) (reportStatus bool) {
-	effective, err := r.callbacks.OnRemoteConfig(ctx, config)
-	if err == nil {
-		r.sender.UpdateNextStatus(func(statusReport *protobufs.StatusReport) {
-			statusReport.EffectiveConfig = effective
-		})
-		if effective != nil {
+	if config == nil {
+		// The remote config is unchanged, nothing to report.
+		effective, err := r.callbacks.OnRemoteConfig(ctx, config)
+		if err == nil && effective != nil {
+			r.sender.UpdateNextStatus(func(statusReport *protobufs.StatusReport) {
+				statusReport.EffectiveConfig = effective
+			})
 			return true
 		}
+		return false
+	}
+
+	// Let the server know that we are applying the config.
+	r.sender.UpdateNextStatus(func(statusReport *protobufs.StatusReport) {
+		statusReport.RemoteConfigStatus = &protobufs.RemoteConfigStatus{
+			LastRemoteConfigHash: config.ConfigHash,
+			Status:               protobufs.RemoteConfigStatus_Applying,
+		}
+	})
+	r.sender.ScheduleSend()
+
+	effective, err := r.callbacks.OnRemoteConfig(ctx, config)
+
+	// Report the result of applying the config.
+	status := &protobufs.RemoteConfigStatus{
+		LastRemoteConfigHash: config.ConfigHash,
+		Status:               protobufs.RemoteConfigStatus_Applied,
 	}
-	return false
+	if err != nil {
+		r.logger.Error("Cannot apply remote config", logging.Err(err))
+		status.Status = protobufs.RemoteConfigStatus_Failed
+		status.ErrorMessage = err.Error()
+	}
+	r.sender.UpdateNextStatus(func(statusReport *protobufs.StatusReport) {
+		statusReport.RemoteConfigStatus = status
+		if err == nil && effective != nil {
+			// Nil effective config means it is unchanged, so keep the last
+			// known one in the state.
+			statusReport.EffectiveConfig = effective
+		}
+	})
+	return true
 }
 
-func (r *Receiver) rcvConnectionSettings(ctx context.Context, settings *protobufs.ConnectionSettingsOffers) {
+// dispatchConnectionSettings dispatches processing of the connection settings
+// offers. The offers in a message replace all previous offers, so processing of
+// the pending offers is not needed if newer offers are received.
+func (r *Receiver) dispatchConnectionSettings(settings *protobufs.ConnectionSettingsOffers) {
 	if settings == nil {
 		return
 	}
+	r.dispatcher.Dispatch(ConnectionSettingsCallback, func(ctx context.Context) {
+		r.rcvConnectionSettings(ctx, settings)
+	})
+}
+
+func (r *Receiver) rcvConnectionSettings(ctx context.Context, settings *protobufs.ConnectionSettingsOffers) {
+
+	statuses := &protobufs.ConnectionStatuses{
+		LastConnectionSettingsHash: settings.Hash,
+	}
 
 	if settings.Opamp != nil {
-		err := r.callbacks.OnOpampConnectionSettings(ctx, settings.Opamp)
-		if err == nil {
-			// TODO: verify connection using new settings.
-			r.callbacks.OnOpampConnectionSettingsAccepted(settings.Opamp)
+		err := r.rcvOpampConnectionSettings(ctx, settings.Opamp)
+		if err != nil {
+			r.logger.Warn("OpAMP connection settings rejected", logging.Err(err))
 		}
+		statuses.Opamp = connectionStatus(err)
 	}
 
-	r.rcvOwnTelemetryConnectionSettings(
+	statuses.OwnMetrics = r.rcvOwnTelemetryConnectionSettings(
 		ctx,
 		settings.OwnMetrics,
 		types.OwnMetrics,
 		r.callbacks.OnOwnTelemetryConnectionSettings,
 	)
 
-	r.rcvOwnTelemetryConnectionSettings(
+	statuses.OwnTraces = r.rcvOwnTelemetryConnectionSettings(
 		ctx,
 		settings.OwnTraces,
 		types.OwnTraces,
 		r.callbacks.OnOwnTelemetryConnectionSettings,
 	)
 
-	r.rcvOwnTelemetryConnectionSettings(
+	statuses.OwnLogs = r.rcvOwnTelemetryConnectionSettings(
 		ctx,
 		settings.OwnLogs,
 		types.OwnLogs,
@@ -125,8 +191,47 @@ func (r *Receiver) rcvConnectionSettings(ctx context.Context, settings *protobuf
 	)
 
 	for name, s := range settings.OtherConnections {
-		r.rcvOtherConnectionSettings(ctx, s, name, r.callbacks.OnOtherConnectionSettings)
+		status := r.rcvOtherConnectionSettings(ctx, s, name, r.callbacks.OnOtherConnectionSettings)
+		if status != nil {
+			if statuses.OtherConnections == nil {
+				statuses.OtherConnections = map[string]*protobufs.ConnectionStatus{}
+			}
+			statuses.OtherConnections[name] = status
+		}
+	}
+
+	// Report the results of processing of the offers back to the server.
+	r.sender.UpdateNextStatus(func(statusReport *protobufs.StatusReport) {
+		statusReport.ConnectionStatuses = statuses
+	})
+	r.sender.ScheduleSend()
+}
+
+// connectionStatus converts the result of processing of a connection settings
+// offer to the status to report to the server.
+func connectionStatus(err error) *protobufs.ConnectionStatus {
+	if err != nil {
+		return &protobufs.ConnectionStatus{
+			Status:       protobufs.ConnectionStatus_Rejected,
+			ErrorMessage: err.Error(),
+		}
 	}
+	return &protobufs.ConnectionStatus{Status: protobufs.ConnectionStatus_Accepted}
+}
+
+func (r *Receiver) rcvOpampConnectionSettings(ctx context.Context, settings *protobufs.ConnectionSettings) error {
+	if err := r.callbacks.OnOpampConnectionSettings(ctx, settings); err != nil {
+		return err
+	}
+
+	// The agent is willing to use the new settings. Verify that we can actually
+	// connect using them before accepting.
+	if err := r.applyOpampSettings(ctx, settings); err != nil {
+		return err
+	}
+
+	r.callbacks.OnOpampConnectionSettingsAccepted(settings)
+	return nil
 }
 
 func (r *Receiver) rcvOwnTelemetryConnectionSettings(
@@ -134,10 +239,11 @@ func (r *Receiver) rcvOwnTelemetryConnectionSettings(
 	settings *protobufs.ConnectionSettings,
 	telemetryType types.OwnTelemetryType,
 	callback func(ctx context.Context, telemetryType types.OwnTelemetryType, settings *protobufs.ConnectionSettings) error,
-) {
-	if settings != nil {
-		callback(ctx, telemetryType, settings)
+) *protobufs.ConnectionStatus {
+	if settings == nil {
+		return nil
 	}
+	return connectionStatus(callback(ctx, telemetryType, settings))
 }
 
 func (r *Receiver) rcvOtherConnectionSettings(
@@ -145,16 +251,68 @@ func (r *Receiver) rcvOtherConnectionSettings(
 	settings *protobufs.ConnectionSettings,
 	name string,
 	callback func(ctx context.Context, name string, settings *protobufs.ConnectionSettings) error,
-) {
-	if settings != nil {
-		callback(ctx, name, settings)
+) *protobufs.ConnectionStatus {
+	if settings == nil {
+		return nil
 	}
+	return connectionStatus(callback(ctx, name, settings))
 }
 
-func (r *Receiver) processErrorResponse(body *protobufs.ServerErrorResponse) {
-	// TODO: implement this.
+func (r *Receiver) processErrorResponse(body *protobufs.ServerErrorResponse) (retryAfter OptionalDuration) {
+	if r.callbacks != nil {
+		r.callbacks.OnError(body)
+	}
+
+	switch body.Type {
+	case protobufs.ServerErrorResponse_BadRequest:
+		// The server could not process our message, possibly because it does
+		// not know the state that our message was relative to. Resend the full
+		// state, but only once until the server responds successfully, to avoid
+		// resending it endlessly.
+		if !r.fullStateResent {
+			r.fullStateResent = true
+			r.sender.ScheduleFullStateSend()
+		}
+
+	case protobufs.ServerErrorResponse_Unavailable:
+		// The server asks us to go away and retry later.
+		retryAfter = OptionalDuration{Defined: true}
+		if retryInfo := body.GetRetryInfo(); retryInfo != nil {
+			retryAfter.Duration = time.Duration(retryInfo.RetryAfterNanoseconds)
+		}
+		r.logger.Warn("Server is unavailable, will retry later", logging.String("message", body.ErrorMessage))
+	}
+	return retryAfter
 }
 
-func (r *Receiver) rcvAddonsAvailable(addons *protobufs.AddonsAvailable) {
-	// TODO: implement this.
+func (r *Receiver) dispatchAddonsAvailable(addons *protobufs.AddonsAvailable) {
+	if addons == nil {
+		return
+	}
+	r.dispatcher.Dispatch(AddonsAvailableCallback, func(ctx context.Context) {
+		r.rcvAddonsAvailable(ctx, addons)
+	})
+}
+
+func (r *Receiver) rcvAddonsAvailable(ctx context.Context, addons *protobufs.AddonsAvailable) {
+	syncer := NewAddonSyncer(r.logger, addons, r.sender)
+	if err := r.callbacks.OnAddonsAvailable(ctx, addons, syncer); err != nil {
+		r.logger.Error("Cannot process available addons", logging.Err(err))
+	}
+}
+
+func (r *Receiver) dispatchAgentPackageAvailable(packageAvailable *protobufs.AgentPackageAvailable) {
+	if packageAvailable == nil {
+		return
+	}
+	r.dispatcher.Dispatch(AgentPackageAvailableCallback, func(ctx context.Context) {
+		r.rcvAgentPackageAvailable(ctx, packageAvailable)
+	})
+}
+
+func (r *Receiver) rcvAgentPackageAvailable(ctx context.Context, packageAvailable *protobufs.AgentPackageAvailable) {
+	syncer := NewAgentPackageSyncer(r.logger, packageAvailable, r.sender)
+	if err := r.callbacks.OnAgentPackageAvailable(ctx, packageAvailable, syncer); err != nil {
+		r.logger.Error("Cannot process available agent package", logging.Err(err))
+	}
 }
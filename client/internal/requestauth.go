@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+)
+
+// RequestAuth adds the headers that may change over time, e.g. the access
+// token, to the requests made to the OpAMP Server. A nil RequestAuth adds
+// nothing.
+type RequestAuth struct {
+	// Provides the token sent in the Authorization header. Optional.
+	TokenSource types.TokenSource
+
+	// Provides additional headers. Optional.
+	HeaderProvider func(ctx context.Context) (http.Header, error)
+}
+
+// Header returns the specified header with the dynamic headers added. The
+// headers returned by HeaderProvider take precedence over the token, which
+// takes precedence over the specified header. The specified header is not
+// modified.
+func (a *RequestAuth) Header(ctx context.Context, header http.Header) (http.Header, error) {
+	if a == nil || (a.TokenSource == nil && a.HeaderProvider == nil) {
+		return header, nil
+	}
+
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if a.TokenSource != nil {
+		token, err := a.TokenSource.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain access token: %w", err)
+		}
+		header.Set("Authorization", token.AuthorizationHeader())
+	}
+	if a.HeaderProvider != nil {
+		provided, err := a.HeaderProvider(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain request headers: %w", err)
+		}
+		for k, v := range provided {
+			header[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	return header, nil
+}
+
+// Refresh discards the cached token after the server rejected the request with
+// 401 Unauthorized. Returns true if the headers may be different next time, in
+// which case the request should be retried immediately.
+func (a *RequestAuth) Refresh() bool {
+	if a == nil || (a.TokenSource == nil && a.HeaderProvider == nil) {
+		return false
+	}
+	if a.TokenSource != nil {
+		a.TokenSource.Invalidate()
+	}
+	return true
+}
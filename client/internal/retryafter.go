@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAfterHTTPHeader is the HTTP response header that tells how long to wait
+// before contacting the server again.
+const RetryAfterHTTPHeader = "Retry-After"
+
+// ExtractRetryAfterHeader extracts Retry-After response header if the status
+// is 503 or 429. Returns undefined duration if the header is not found or the
+// status is different.
+func ExtractRetryAfterHeader(resp *http.Response) OptionalDuration {
+	if resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusTooManyRequests {
+		retryAfter := strings.TrimSpace(resp.Header.Get(RetryAfterHTTPHeader))
+		if retryAfter != "" {
+			retryIntervalSec, err := strconv.Atoi(retryAfter)
+			if err == nil {
+				retryInterval := time.Duration(retryIntervalSec) * time.Second
+				return OptionalDuration{Defined: true, Duration: retryInterval}
+			}
+		}
+	}
+	return OptionalDuration{Defined: false}
+}
+
+// RetryInterval returns the interval to wait before the next attempt to
+// contact the server.
+func RetryInterval(interval time.Duration, retryAfter OptionalDuration) time.Duration {
+	if retryAfter.Defined && retryAfter.Duration > interval {
+		// If the server suggested connecting later than our interval
+		// then honour server's request, otherwise wait at least
+		// as much as we calculated.
+		return retryAfter.Duration
+	}
+	return interval
+}
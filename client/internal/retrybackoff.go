@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// RetryBackoff calculates how long to wait before the next attempt to connect
+// to the server or to send a message to it after a failure, and waits.
+// RetryBackoff is not safe for concurrent use.
+type RetryBackoff struct {
+	backOff backoff.BackOff
+	clock   Clock
+
+	// The backoff is reset when a connection that was up for at least
+	// resetAfter is lost.
+	resetAfter  time.Duration
+	connectedAt time.Time
+}
+
+func NewRetryBackoff(backOff backoff.BackOff, clock Clock, resetAfter time.Duration) *RetryBackoff {
+	return &RetryBackoff{
+		backOff:    backOff,
+		clock:      clock,
+		resetAfter: resetAfter,
+	}
+}
+
+// NextInterval returns the interval to wait before the next attempt. If the
+// server specified when to retry and that is later than the interval, the
+// time specified by the server is returned.
+func (b *RetryBackoff) NextInterval(retryAfter OptionalDuration) time.Duration {
+	interval := b.backOff.NextBackOff()
+	if interval == backoff.Stop {
+		// We never give up, start over.
+		b.backOff.Reset()
+		interval = b.backOff.NextBackOff()
+	}
+	return RetryInterval(interval, retryAfter)
+}
+
+// Wait waits for the specified interval. Returns ctx.Err() if the ctx is done
+// before that.
+func (b *RetryBackoff) Wait(ctx context.Context, interval time.Duration) error {
+	timer := b.clock.NewTimer(interval)
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	}
+}
+
+// Reset makes the next interval the initial one.
+func (b *RetryBackoff) Reset() {
+	b.backOff.Reset()
+}
+
+// Connected records that the connection is established.
+func (b *RetryBackoff) Connected() {
+	b.connectedAt = b.clock.Now()
+}
+
+// Disconnected records that the connection is lost. Resets the backoff if the
+// connection was stable, i.e. it was up for long enough.
+func (b *RetryBackoff) Disconnected() {
+	if !b.connectedAt.IsZero() && b.clock.Now().Sub(b.connectedAt) >= b.resetAfter {
+		b.backOff.Reset()
+	}
+	b.connectedAt = time.Time{}
+}
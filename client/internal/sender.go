@@ -1,70 +1,70 @@
 package internal
 
 import (
-	"context"
 	"sync"
 
-	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
-	"github.com/open-telemetry/opamp-go/client/types"
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
-// Sender implements the client's sending portion of OpAMP protocol.
+// Sender keeps the updates that need to be sent to the server and schedules
+// sending them. The actual sending is done by a transport-specific sender
+// (WSSender or HTTPSender) that uses the Sender.
 type Sender struct {
-	instanceUid string
-	conn        *websocket.Conn
-
-	logger types.Logger
-
 	// Indicates that there are pending messages to send.
 	hasMessages chan struct{}
 
 	// The next message to send.
-	nextMessage protobufs.AgentToServer
+	nextMessage *protobufs.AgentToServer
 	// Indicates that nextMessage is pending to be sent.
 	messagePending bool
-	// Mutex to protect the above 2 fields.
+	// The full state of the agent, i.e. all the updates that were ever made to
+	// the next message merged together. Used when the full state needs to be
+	// sent again.
+	fullState *protobufs.AgentToServer
+	// Called when the full state is updated. Optional.
+	fullStateListener func(fullState *protobufs.AgentToServer)
+	// Mutex to protect the above 4 fields.
 	messageMutex sync.Mutex
 
-	// Indicates that the sender has fully stopped.
-	stopped chan struct{}
+	// The capabilities advertised by the server. The data that the server does
+	// not accept is not sent. Protected by capabilitiesMutex.
+	serverCapabilities protobufs.ServerCapabilities
+	capabilitiesMutex  sync.RWMutex
 }
 
-func NewSender(logger types.Logger) *Sender {
+func NewSender() *Sender {
 	return &Sender{
-		logger:      logger,
 		hasMessages: make(chan struct{}, 1),
+		nextMessage: &protobufs.AgentToServer{},
+		fullState:   &protobufs.AgentToServer{},
 	}
 }
 
-// Start the sender and send the first message that was set via UpdateNextMessage()
-// earlier. To stop the Sender cancel the ctx.
-func (s *Sender) Start(ctx context.Context, instanceUid string, conn *websocket.Conn) error {
-	s.conn = conn
-	s.instanceUid = instanceUid
-	err := s.sendNextMessage()
-
-	// Run the sender in the background.
-	s.stopped = make(chan struct{})
-	go s.run(ctx)
-
-	return err
-}
-
-// WaitToStop blocks until the sender is stopped. To stop the sender cancel the context
-// that was passed to Start().
-func (s *Sender) WaitToStop() {
-	<-s.stopped
-}
-
 // UpdateNextMessage applies the specified modifier function to the next message that
 // will be sent and marks the message as pending to be sent.
+// The modifier is also applied to the full state of the agent, so it must only
+// set fields and must not depend on the current content of the message.
 func (s *Sender) UpdateNextMessage(modifier func(msg *protobufs.AgentToServer)) {
 	s.messageMutex.Lock()
-	modifier(&s.nextMessage)
+	modifier(s.nextMessage)
+	modifier(s.fullState)
 	s.messagePending = true
+	if s.fullStateListener != nil {
+		s.fullStateListener(s.fullState)
+	}
+	s.messageMutex.Unlock()
+}
+
+// SetFullStateListener sets the function that is called every time the full
+// state of the agent is updated. The function is called while the Sender is
+// locked, so it must not call the Sender and must not retain or modify the
+// full state.
+func (s *Sender) SetFullStateListener(listener func(fullState *protobufs.AgentToServer)) {
+	s.messageMutex.Lock()
+	s.fullStateListener = listener
 	s.messageMutex.Unlock()
 }
 
@@ -73,10 +73,10 @@ func (s *Sender) UpdateNextMessage(modifier func(msg *protobufs.AgentToServer))
 func (s *Sender) UpdateNextStatus(modifier func(statusReport *protobufs.StatusReport)) {
 	s.UpdateNextMessage(
 		func(msg *protobufs.AgentToServer) {
-			if s.nextMessage.StatusReport == nil {
-				s.nextMessage.StatusReport = &protobufs.StatusReport{}
+			if msg.StatusReport == nil {
+				msg.StatusReport = &protobufs.StatusReport{}
 			}
-			modifier(s.nextMessage.StatusReport)
+			modifier(msg.StatusReport)
 		},
 	)
 }
@@ -92,55 +92,159 @@ func (s *Sender) ScheduleSend() {
 	}
 }
 
-func (s *Sender) run(ctx context.Context) {
-out:
-	for {
-		select {
-		case <-s.hasMessages:
-			s.sendNextMessage()
+// ScheduleFullStateSend marks the full state of the agent as pending to be sent
+// and signals to the sending goroutine to send it.
+func (s *Sender) ScheduleFullStateSend() {
+	s.messageMutex.Lock()
+	s.nextMessage = proto.Clone(s.fullState).(*protobufs.AgentToServer)
+	s.messagePending = true
+	s.messageMutex.Unlock()
 
-		case <-ctx.Done():
-			break out
+	s.ScheduleSend()
+}
+
+// ScheduleEffectiveConfigSend marks the last known effective config of the
+// agent as pending to be sent and signals to the sending goroutine to send it.
+// Does nothing if the effective config is not known.
+func (s *Sender) ScheduleEffectiveConfigSend() {
+	s.messageMutex.Lock()
+	config := s.fullState.GetStatusReport().GetEffectiveConfig()
+	if config != nil {
+		if s.nextMessage.StatusReport == nil {
+			s.nextMessage.StatusReport = &protobufs.StatusReport{}
 		}
+		s.nextMessage.StatusReport.EffectiveConfig = config
+		s.messagePending = true
+	}
+	s.messageMutex.Unlock()
+
+	if config != nil {
+		s.ScheduleSend()
+	}
+}
+
+// ScheduleAddonStatusesSend marks the last known addon statuses of the agent
+// as pending to be sent and signals to the sending goroutine to send them.
+// Does nothing if the addon statuses are not known.
+func (s *Sender) ScheduleAddonStatusesSend() {
+	s.messageMutex.Lock()
+	statuses := s.fullState.AddonStatuses
+	if statuses != nil {
+		s.nextMessage.AddonStatuses = statuses
+		s.messagePending = true
 	}
+	s.messageMutex.Unlock()
+
+	if statuses != nil {
+		s.ScheduleSend()
+	}
+}
 
-	close(s.stopped)
+// SetServerCapabilities remembers the capabilities advertised by the server.
+func (s *Sender) SetServerCapabilities(capabilities protobufs.ServerCapabilities) {
+	s.capabilitiesMutex.Lock()
+	s.serverCapabilities = capabilities
+	s.capabilitiesMutex.Unlock()
 }
 
-func (s *Sender) sendNextMessage() error {
+// ServerCapabilities returns the capabilities advertised by the server, or
+// UnspecifiedServerCapability if the server did not advertise them yet.
+func (s *Sender) ServerCapabilities() protobufs.ServerCapabilities {
+	s.capabilitiesMutex.RLock()
+	defer s.capabilitiesMutex.RUnlock()
+	return s.serverCapabilities
+}
+
+// removeNotAccepted removes from the message the data that the server does not
+// accept according to its capabilities. If the capabilities are not known yet
+// the message is not changed.
+func (s *Sender) removeNotAccepted(msg *protobufs.AgentToServer) {
+	caps := s.ServerCapabilities()
+	if caps == protobufs.ServerCapabilities_UnspecifiedServerCapability {
+		return
+	}
+	if caps&protobufs.ServerCapabilities_AcceptsEffectiveConfig == 0 && msg.StatusReport != nil {
+		msg.StatusReport.EffectiveConfig = nil
+	}
+	if caps&protobufs.ServerCapabilities_AcceptsAddonsStatus == 0 {
+		msg.AddonStatuses = nil
+	}
+	if caps&protobufs.ServerCapabilities_AcceptsAgentPackageStatus == 0 {
+		msg.AgentInstallStatus = nil
+	}
+}
+
+// takeNextMessage returns a copy of the pending message and resets the pending
+// message. Returns nil if there is no pending message. The data that the server
+// does not accept is removed from the returned message.
+func (s *Sender) takeNextMessage() *protobufs.AgentToServer {
 	var msgToSend *protobufs.AgentToServer
 	s.messageMutex.Lock()
 	if s.messagePending {
 		// Clone the message to have a copy for sending and avoid blocking
 		// future updates to s.nextMessage field.
-		msgToSend = proto.Clone(&s.nextMessage).(*protobufs.AgentToServer)
+		msgToSend = proto.Clone(s.nextMessage).(*protobufs.AgentToServer)
 		s.messagePending = false
 
 		// Reset fields that we do not have to send unless they change before the
 		// next report after this one.
-		s.nextMessage = protobufs.AgentToServer{}
+		s.nextMessage = &protobufs.AgentToServer{}
 	}
 	s.messageMutex.Unlock()
 
-	if msgToSend != nil && !proto.Equal(msgToSend, &protobufs.AgentToServer{}) {
-		// There is a pending message and the message has some fields populated.
-		// Set the InstanceUid field and send it.
-		msgToSend.InstanceUid = s.instanceUid
-		return s.sendMessage(msgToSend)
+	if msgToSend != nil {
+		s.removeNotAccepted(msgToSend)
 	}
-	return nil
+	return msgToSend
 }
 
-func (s *Sender) sendMessage(msg *protobufs.AgentToServer) error {
-	data, err := proto.Marshal(msg)
-	if err != nil {
-		s.logger.Errorf("Cannot marshal data: %v", err)
-		return err
-	}
-	err = s.conn.WriteMessage(websocket.BinaryMessage, data)
-	if err != nil {
-		s.logger.Errorf("Cannot send: %v", err)
-		// TODO: propagate error back to Client and reconnect.
+// takeFullState returns a copy of the full state of the agent and resets the
+// pending message, which is included in the full state. Used for the first
+// message on a new connection, since the server may know nothing about the
+// agent (e.g. it restarted or it is a different server). The data that the
+// server does not accept is removed from the returned message.
+func (s *Sender) takeFullState() *protobufs.AgentToServer {
+	s.messageMutex.Lock()
+	msgToSend := proto.Clone(s.fullState).(*protobufs.AgentToServer)
+	s.messagePending = false
+	s.nextMessage = &protobufs.AgentToServer{}
+	s.messageMutex.Unlock()
+
+	s.removeNotAccepted(msgToSend)
+	return msgToSend
+}
+
+// restoreUnsentMessage merges the message that was taken by takeNextMessage but
+// could not be sent back into the pending message so that it is sent next time.
+// The fields that were updated after the message was taken take precedence.
+func (s *Sender) restoreUnsentMessage(unsent *protobufs.AgentToServer) {
+	s.messageMutex.Lock()
+	defer s.messageMutex.Unlock()
+
+	merged := proto.Clone(unsent).(*protobufs.AgentToServer)
+	if s.nextMessage.StatusReport != nil {
+		// The status report is updated field by field via UpdateNextStatus,
+		// so merge it the same way.
+		if merged.StatusReport == nil {
+			merged.StatusReport = &protobufs.StatusReport{}
+		}
+		setFields(merged.StatusReport, s.nextMessage.StatusReport)
 	}
-	return err
+	s.nextMessage.StatusReport = nil
+	setFields(merged, s.nextMessage)
+
+	s.nextMessage = merged
+	s.messagePending = true
+}
+
+// setFields sets all populated fields of src to dst. Unlike proto.Merge it
+// replaces the fields instead of merging them.
+func setFields(dst, src proto.Message) {
+	dstReflect := dst.ProtoReflect()
+	src.ProtoReflect().Range(
+		func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			dstReflect.Set(fd, v)
+			return true
+		},
+	)
 }
@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+)
+
+// StatusTracker keeps track of the connection state of the client and counts
+// the traffic exchanged with the server. It is updated by the client and by
+// the transport-specific senders and receivers.
+type StatusTracker struct {
+	// Traffic counters, accessed atomically. Kept first in the struct to be
+	// 64-bit aligned.
+	messagesSent     uint64
+	messagesReceived uint64
+	bytesSent        uint64
+	bytesReceived    uint64
+
+	mutex        sync.Mutex
+	state        types.ConnectionState
+	serverURL    string
+	connectedAt  time.Time
+	connectCount int64
+	lastError    error
+	retryAt      time.Time
+}
+
+func NewStatusTracker() *StatusTracker {
+	return &StatusTracker{state: types.StateStopped}
+}
+
+// SetConnecting records that the client is trying to connect to the server.
+func (t *StatusTracker) SetConnecting(serverURL string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.state = types.StateConnecting
+	t.serverURL = serverURL
+	t.connectedAt = time.Time{}
+}
+
+// SetConnected records that the client is connected to the server. Does
+// nothing if already connected to the same server.
+func (t *StatusTracker) SetConnected(serverURL string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.state == types.StateConnected && t.serverURL == serverURL {
+		return
+	}
+	t.state = types.StateConnected
+	t.serverURL = serverURL
+	t.connectedAt = time.Now()
+	t.connectCount++
+}
+
+// SetBackoff records that the client waits for the specified duration before
+// trying to connect or to send again.
+func (t *StatusTracker) SetBackoff(retryIn time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.state = types.StateBackoff
+	t.connectedAt = time.Time{}
+	t.retryAt = time.Now().Add(retryIn)
+}
+
+// SetError records the last error.
+func (t *StatusTracker) SetError(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.lastError = err
+}
+
+// SetStopped records that the client is stopped.
+func (t *StatusTracker) SetStopped() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.state = types.StateStopped
+	t.connectedAt = time.Time{}
+}
+
+// CountSent counts a message of the specified size sent to the server.
+func (t *StatusTracker) CountSent(bytes int) {
+	atomic.AddUint64(&t.messagesSent, 1)
+	atomic.AddUint64(&t.bytesSent, uint64(bytes))
+}
+
+// CountReceived counts a message of the specified size received from the server.
+func (t *StatusTracker) CountReceived(bytes int) {
+	atomic.AddUint64(&t.messagesReceived, 1)
+	atomic.AddUint64(&t.bytesReceived, uint64(bytes))
+}
+
+// Status returns the current status.
+func (t *StatusTracker) Status() types.Status {
+	t.mutex.Lock()
+	status := types.Status{
+		State:       t.state,
+		ServerURL:   t.serverURL,
+		ConnectedAt: t.connectedAt,
+		LastError:   t.lastError,
+	}
+	if t.connectCount > 1 {
+		status.ReconnectCount = t.connectCount - 1
+	}
+	if t.state == types.StateBackoff {
+		if retryIn := time.Until(t.retryAt); retryIn > 0 {
+			status.NextRetryIn = retryIn
+		}
+	}
+	t.mutex.Unlock()
+
+	status.MessagesSent = atomic.LoadUint64(&t.messagesSent)
+	status.MessagesReceived = atomic.LoadUint64(&t.messagesReceived)
+	status.BytesSent = atomic.LoadUint64(&t.bytesSent)
+	status.BytesReceived = atomic.LoadUint64(&t.bytesReceived)
+	return status
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+
+	sharedinternal "github.com/open-telemetry/opamp-go/internal"
+	"github.com/open-telemetry/opamp-go/logging"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// WSReceiver implements the client's receiving portion of OpAMP protocol over
+// a WebSocket connection.
+type WSReceiver struct {
+	conn      *websocket.Conn
+	keepalive *sharedinternal.WSKeepalive
+	logger    logging.Logger
+	receiver  *Receiver
+	status    *StatusTracker
+}
+
+func NewWSReceiver(
+	logger logging.Logger,
+	conn *websocket.Conn,
+	keepalive *sharedinternal.WSKeepalive,
+	receiver *Receiver,
+	status *StatusTracker,
+) *WSReceiver {
+	return &WSReceiver{
+		conn:      conn,
+		keepalive: keepalive,
+		logger:    logger,
+		receiver:  receiver,
+		status:    status,
+	}
+}
+
+// ReceiverLoop receives and processes messages until an error happens or until
+// the server reports that it is unavailable. In the latter case the returned
+// retryAfter is defined and indicates how long the client should wait before
+// reconnecting (zero if the server did not specify it).
+func (r *WSReceiver) ReceiverLoop(ctx context.Context) (retryAfter OptionalDuration) {
+out:
+	for {
+		var message protobufs.ServerToAgent
+		if err := r.receiveMessage(&message); err != nil {
+			if ctx.Err() == nil && !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				r.logger.Warn("Unexpected error while receiving", logging.Err(err))
+				r.status.SetError(err)
+			}
+			break out
+		} else {
+			retryAfter = r.receiver.ProcessReceivedMessage(&message)
+			if retryAfter.Defined {
+				// The server is unavailable, stop receiving.
+				break out
+			}
+		}
+	}
+
+	return retryAfter
+}
+
+func (r *WSReceiver) receiveMessage(msg *protobufs.ServerToAgent) error {
+	// The server must send something (at least a pong) before the deadline,
+	// otherwise the connection is considered dead.
+	if err := r.keepalive.ExtendReadDeadline(); err != nil {
+		return err
+	}
+	_, bytes, err := r.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	r.status.CountReceived(len(bytes))
+	err = proto.Unmarshal(bytes, msg)
+	if err != nil {
+		return fmt.Errorf("cannot decode received message: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/logging"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// WSSender implements the client's sending portion of OpAMP protocol over
+// a WebSocket connection.
+type WSSender struct {
+	instanceUid string
+	conn        *websocket.Conn
+
+	logger logging.Logger
+	sender *Sender
+	status *StatusTracker
+
+	// Serializes writes to the connection.
+	writeMutex sync.Mutex
+	// Set when the AgentDisconnect message is sent. No messages can be sent
+	// after that. Protected by writeMutex.
+	disconnected bool
+
+	// Indicates that the sender has fully stopped.
+	stopped chan struct{}
+}
+
+func NewWSSender(logger logging.Logger, sender *Sender, status *StatusTracker) *WSSender {
+	return &WSSender{
+		logger: logger,
+		sender: sender,
+		status: status,
+	}
+}
+
+// Start the sender and send the full state of the agent as the first message on
+// the connection. To stop the WSSender cancel the ctx.
+func (s *WSSender) Start(ctx context.Context, instanceUid string, conn *websocket.Conn) error {
+	s.conn = conn
+	s.instanceUid = instanceUid
+	err := s.send(s.sender.takeFullState)
+
+	// Run the sender in the background.
+	s.stopped = make(chan struct{})
+	go s.run(ctx)
+
+	return err
+}
+
+// WaitToStop blocks until the sender is stopped. To stop the sender cancel the context
+// that was passed to Start().
+func (s *WSSender) WaitToStop() {
+	<-s.stopped
+}
+
+func (s *WSSender) run(ctx context.Context) {
+out:
+	for {
+		select {
+		case <-s.sender.hasMessages:
+			s.sendNextMessage()
+
+		case <-ctx.Done():
+			break out
+		}
+	}
+
+	close(s.stopped)
+}
+
+// SendDisconnect sends the pending message (if any) together with the
+// AgentDisconnect message and then sends a WebSocket close frame. No messages
+// are sent after that. Gives up and closes the connection if the ctx is
+// done before the sending is finished.
+func (s *WSSender) SendDisconnect(ctx context.Context) error {
+	// Close the connection if the ctx is done before we are finished to unblock
+	// the writing.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.conn.Close()
+		case <-done:
+		}
+	}()
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if s.disconnected {
+		return nil
+	}
+	s.disconnected = true
+
+	// Zero deadline means no deadline.
+	deadline, _ := ctx.Deadline()
+	s.conn.SetWriteDeadline(deadline)
+
+	msgToSend := s.sender.takeNextMessage()
+	if msgToSend == nil {
+		msgToSend = &protobufs.AgentToServer{}
+	}
+	msgToSend.InstanceUid = s.instanceUid
+	msgToSend.AgentDisconnect = &protobufs.AgentDisconnect{}
+	if err := s.sendMessage(msgToSend); err != nil {
+		// Keep the pending state in case the client is started again.
+		msgToSend.AgentDisconnect = nil
+		s.sender.restoreUnsentMessage(msgToSend)
+		return err
+	}
+
+	return s.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		deadline,
+	)
+}
+
+func (s *WSSender) sendNextMessage() error {
+	return s.send(s.sender.takeNextMessage)
+}
+
+// send sends the message returned by take, unless the AgentDisconnect message
+// was already sent.
+func (s *WSSender) send(take func() *protobufs.AgentToServer) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if s.disconnected {
+		return nil
+	}
+
+	msgToSend := take()
+
+	if msgToSend != nil && !proto.Equal(msgToSend, &protobufs.AgentToServer{}) {
+		// There is a pending message and the message has some fields populated.
+		// Set the InstanceUid field and send it.
+		msgToSend.InstanceUid = s.instanceUid
+		if err := s.sendMessage(msgToSend); err != nil {
+			// Keep the message to send it again once reconnected.
+			s.sender.restoreUnsentMessage(msgToSend)
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *WSSender) sendMessage(msg *protobufs.AgentToServer) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		s.logger.Error("Cannot marshal data", logging.Err(err))
+		return err
+	}
+	err = s.conn.WriteMessage(websocket.BinaryMessage, data)
+	if err != nil {
+		s.logger.Warn("Cannot send", logging.Err(err))
+		s.status.SetError(err)
+		// The connection is broken. Close it, so that the receiving side fails
+		// too and the client reconnects.
+		s.conn.Close()
+		return err
+	}
+	s.status.CountSent(len(data))
+	return nil
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	ulid "github.com/oklog/ulid/v2"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/client/types"
+	"github.com/open-telemetry/opamp-go/logging"
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+// loadState fills in the previously saved state in the settings, unless the
+// corresponding settings are already set. Generates a new InstanceUid if there
+// is none. Returns the loaded state.
+func loadState(logger logging.Logger, settings *StartSettings) (types.State, error) {
+	loaded, err := settings.StateStore.Load()
+	if err != nil {
+		return types.State{}, fmt.Errorf("cannot load state: %w", err)
+	}
+	if loaded == nil {
+		loaded = &types.State{}
+	}
+
+	if settings.InstanceUid == "" {
+		settings.InstanceUid = loaded.InstanceUid
+	}
+	if settings.InstanceUid == "" {
+		uid, err := ulid.New(ulid.Timestamp(time.Now()), rand.Reader)
+		if err != nil {
+			return types.State{}, fmt.Errorf("cannot generate instance uid: %w", err)
+		}
+		settings.InstanceUid = uid.String()
+		logger.Info("Generated new instance uid", logging.InstanceUid(settings.InstanceUid))
+	}
+	if settings.LastRemoteConfigHash == nil {
+		settings.LastRemoteConfigHash = loaded.LastRemoteConfigHash
+	}
+	if settings.LastEffectiveConfig == nil {
+		settings.LastEffectiveConfig = loaded.LastEffectiveConfig
+	}
+	if settings.LastConnectionSettingsHash == nil {
+		settings.LastConnectionSettingsHash = loaded.LastConnectionSettingsHash
+	}
+	if settings.LastServerProvidedAllAddonsHash == nil {
+		settings.LastServerProvidedAllAddonsHash = loaded.LastServerProvidedAllAddonsHash
+	}
+
+	return *loaded, nil
+}
+
+// stateSaver saves the state of the client to the StateStore when the state
+// changes. The state is saved on a separate goroutine, so that the Sender is
+// not blocked while the StateStore writes it. If the state changes while it is
+// being saved only the most recent state is saved next. If saving fails it is
+// tried again on the next update and when waiting for the saving to finish.
+type stateSaver struct {
+	logger      logging.Logger
+	store       types.StateStore
+	instanceUid string
+
+	// Protects the fields below.
+	mutex sync.Mutex
+	// The most recent state, which is saved or is to be saved.
+	latest types.State
+	// The last saved state.
+	saved types.State
+	// Closed when the saving goroutine finishes. Nil if it is not running.
+	idle chan struct{}
+	// The error of the last attempt to save the state, nil if it succeeded.
+	err error
+}
+
+func newStateSaver(logger logging.Logger, store types.StateStore, instanceUid string, loaded types.State) *stateSaver {
+	return &stateSaver{
+		logger:      logger,
+		store:       store,
+		instanceUid: instanceUid,
+		latest:      loaded,
+		saved:       loaded,
+	}
+}
+
+// update schedules saving of the state if the full state of the agent changed
+// the data that needs to be saved. Must be set as the full state listener of
+// the Sender. The data is copied, so that the full state is not accessed after
+// update returns.
+func (s *stateSaver) update(fullState *protobufs.AgentToServer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := s.latest
+	state.InstanceUid = s.instanceUid
+
+	statusReport := fullState.GetStatusReport()
+	remoteConfigStatus := statusReport.GetRemoteConfigStatus()
+	if remoteConfigStatus != nil && remoteConfigStatus.Status == protobufs.RemoteConfigStatus_Applied {
+		// Only the applied configs are saved, so that the server sends the
+		// config again after restart if it failed or was not applied yet.
+		state.LastRemoteConfigHash = copyBytes(remoteConfigStatus.LastRemoteConfigHash)
+	}
+	if statusReport.GetEffectiveConfig() != nil && !proto.Equal(statusReport.EffectiveConfig, state.LastEffectiveConfig) {
+		state.LastEffectiveConfig = proto.Clone(statusReport.EffectiveConfig).(*protobufs.EffectiveConfig)
+	}
+	if connectionStatuses := statusReport.GetConnectionStatuses(); connectionStatuses != nil &&
+		!connectionSettingsRejected(connectionStatuses) {
+		// Similarly, the server must offer the connection settings again
+		// after restart if they were rejected.
+		state.LastConnectionSettingsHash = copyBytes(connectionStatuses.LastConnectionSettingsHash)
+	}
+	if addonStatuses := fullState.GetAddonStatuses(); addonStatuses != nil {
+		state.LastServerProvidedAllAddonsHash = copyBytes(addonStatuses.ServerProvidedAllAddonsHash)
+	}
+
+	s.latest = state
+	s.startSaving()
+}
+
+// startSaving starts the saving goroutine if the latest state is not saved
+// and the goroutine is not running. Must be called with the mutex locked.
+func (s *stateSaver) startSaving() {
+	if s.idle == nil && !statesEqual(&s.latest, &s.saved) {
+		s.idle = make(chan struct{})
+		go s.saveLoop()
+	}
+}
+
+// saveLoop saves the most recent state until the saved state is up to date.
+func (s *stateSaver) saveLoop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for !statesEqual(&s.latest, &s.saved) {
+		state := s.latest
+		s.mutex.Unlock()
+		err := s.store.Save(&state)
+		s.mutex.Lock()
+		s.err = err
+		if err != nil {
+			// The next update will try again.
+			s.logger.Error("Cannot save state", logging.Err(err))
+			break
+		}
+		s.saved = state
+	}
+	close(s.idle)
+	s.idle = nil
+}
+
+// wait waits until the latest state is saved or the context is done. If the
+// latest state failed to save it is tried again. Returns the error of the last
+// attempt to save the state.
+func (s *stateSaver) wait(ctx context.Context) error {
+	s.mutex.Lock()
+	s.startSaving()
+	idle := s.idle
+	s.mutex.Unlock()
+	if idle != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-idle:
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// connectionSettingsRejected returns true if any of the connection settings
+// offers reported in the statuses was rejected.
+func connectionSettingsRejected(statuses *protobufs.ConnectionStatuses) bool {
+	rejected := func(status *protobufs.ConnectionStatus) bool {
+		return status.GetStatus() == protobufs.ConnectionStatus_Rejected
+	}
+	if rejected(statuses.Opamp) || rejected(statuses.OwnMetrics) ||
+		rejected(statuses.OwnTraces) || rejected(statuses.OwnLogs) {
+		return true
+	}
+	for _, status := range statuses.OtherConnections {
+		if rejected(status) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func statesEqual(a, b *types.State) bool {
+	return a.InstanceUid == b.InstanceUid &&
+		bytes.Equal(a.LastRemoteConfigHash, b.LastRemoteConfigHash) &&
+		proto.Equal(a.LastEffectiveConfig, b.LastEffectiveConfig) &&
+		bytes.Equal(a.LastConnectionSettingsHash, b.LastConnectionSettingsHash) &&
+		bytes.Equal(a.LastServerProvidedAllAddonsHash, b.LastServerProvidedAllAddonsHash)
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+)
+
+var errNoCertificate = errors.New("no certificate found")
+
+// NewTLSConfig creates a TLS config from the certificate offered by the server,
+// e.g. in ConnectionSettings. The config can be used by the Agent when handling
+// own telemetry and other connection settings offers.
+//
+// The config presents the certificate to the servers that ask for a client
+// certificate (mutual TLS). The servers are verified using the system roots.
+// The CA of the certificate, if specified, is only verified to be a valid
+// certificate: it is the CA that issued the client certificate and must not
+// be trusted as an authority for any purpose.
+//
+// The keys may be PEM-encoded, which is the encoding the OpAMP spec requires,
+// or DER-encoded. Returns an error if the keys cannot be decoded or if the
+// private key does not match the certificate.
+func NewTLSConfig(certificate *protobufs.TLSCertificate) (*tls.Config, error) {
+	cert, err := parseKeyPair(certificate.PublicKey, certificate.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if len(certificate.CaPublicKey) > 0 {
+		if _, err := parseCertificates(certificate.CaPublicKey); err != nil {
+			return nil, fmt.Errorf("invalid CA certificate: %w", err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// tlsConfigWithCertificate returns a copy of the base config (or a new config
+// if the base is nil) that uses the specified certificate as described in
+// NewTLSConfig. The servers are verified the same way as with the base config.
+func tlsConfigWithCertificate(base *tls.Config, certificate *protobufs.TLSCertificate) (*tls.Config, error) {
+	offered, err := NewTLSConfig(certificate)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return offered, nil
+	}
+
+	cfg := base.Clone()
+	cfg.Certificates = offered.Certificates
+	return cfg, nil
+}
+
+// parseKeyPair parses the PEM- or DER-encoded certificate chain and private key
+// and verifies that they match.
+func parseKeyPair(certData, keyData []byte) (tls.Certificate, error) {
+	certs, err := parseCertificates(certData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid certificate: %w", err)
+	}
+	keyPEM, err := privateKeyPEM(keyData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	// Let the tls package verify that the key matches the certificate.
+	var certPEM []byte
+	for _, cert := range certs {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("invalid certificate: %w", err)
+	}
+	return pair, nil
+}
+
+// parseCertificates parses the PEM-encoded certificates. If the data is not
+// PEM-encoded it is parsed as a DER-encoded certificate.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+	if len(data) == 0 {
+		return nil, errNoCertificate
+	}
+
+	// Not PEM, try DER.
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errNoCertificate, err)
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+// privateKeyPEM returns the PEM encoding of the private key. If the data is
+// not PEM-encoded it is parsed as a DER-encoded PKCS #8, PKCS #1 or EC key.
+func privateKeyPEM(data []byte) ([]byte, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		return data, nil
+	}
+
+	if _, err := x509.ParsePKCS8PrivateKey(data); err == nil {
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), nil
+	}
+	if _, err := x509.ParsePKCS1PrivateKey(data); err == nil {
+		return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: data}), nil
+	}
+	if _, err := x509.ParseECPrivateKey(data); err == nil {
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: data}), nil
+	}
+	return nil, errors.New("unsupported private key encoding")
+}
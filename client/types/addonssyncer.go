@@ -13,6 +13,9 @@ type AddonSyncer interface {
 	// The agent must supply an AddonStateProvider to let the Sync function
 	// know what is available locally, what data needs to be sync and how the
 	// data can be stored locally.
+	// The content of the downloaded addon files is verified against the
+	// content_hash of the files, which must be the SHA-256 hash of the content.
+	// The files without content_hash are not verified.
 	Sync(ctx context.Context, localState AddonStateProvider) error
 }
 
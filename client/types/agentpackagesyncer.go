@@ -13,7 +13,15 @@ type AgentPackageSyncer interface {
 	// The agent must supply an AgentPackageStateProvider to let the Sync function
 	// know what is available locally, what data needs to be sync and how the
 	// data can be stored locally.
-	Sync(localAddonState *AgentPackageStateProvider) error
+	// The progress of the syncing is automatically reported to the server via
+	// AgentInstallStatus message. If UpdateContent of the localState returns an
+	// error that wraps os.ErrPermission the status is reported as
+	// InstallNoPermission, any other error is reported as InstallFailed.
+	// The content of the downloaded package is verified against the content_hash
+	// of the file, which must be the SHA-256 hash of the content. The package
+	// without content_hash is not verified.
+	// Sync blocks until the package is synced or until the ctx is cancelled.
+	Sync(ctx context.Context, localState AgentPackageStateProvider) error
 }
 
 // AgentPackageStateProvider allows AgentPackageSyncer to assess the local state
@@ -15,17 +15,23 @@ const (
 )
 
 type Callbacks interface {
-	OnConnect()
+	// OnConnect is called when the connection to the server is established.
+	// The serverURL is the endpoint the client is connected to, which may be
+	// a failover endpoint.
+	OnConnect(serverURL string)
 
 	// OnConnectFailed is called when the connection to the server cannot be established.
 	// May be called after Start() is called and tries to connect to the server.
 	// May also be called if the connection is lost and reconnection attempt fails.
-	OnConnectFailed(err error)
+	// The serverURL is the endpoint the client failed to connect to.
+	OnConnectFailed(serverURL string, err error)
 
 	// OnError is called when the server reports an error in response to some previously
 	// sent request. Useful for logging purposes. The Agent should not attempt to process
 	// the error by reconnecting or retrying previous operations. The client handles the
 	// ErrorResponse_UNAVAILABLE case internally by performing retries as necessary.
+	// The ErrorResponse_BAD_REQUEST case is handled by resending the full status of
+	// the Agent to the server.
 	OnError(err *protobufs.ServerErrorResponse)
 
 	// OnRemoteConfig is called when the agent receives a remote config from the server.
@@ -37,6 +43,7 @@ type Callbacks interface {
 	// succeeded or an error if processing failed.
 	// The returned effective config or the error will be reported back to the server
 	// via StatusReport message (using EffectiveConfig and RemoteConfigStatus fields).
+	// A nil effective config means that the effective config did not change.
 	//
 	// Only one OnRemoteConfig call can be active at any time. Until OnRemoteConfig
 	// returns it will not be called again. Any other remote configs received from
@@ -51,7 +58,7 @@ type Callbacks interface {
 	// OnOpampConnectionSettings is called when the agent receives an OpAMP
 	// connection settings offer from the server. Typically the settings can specify
 	// authorization headers or TLS certificate, potentially also a different
-	// OpAMP destination to work with.
+	// OpAMP destination or a proxy to work with.
 	//
 	// The Agent should process the offer and return an error if the Agent does not
 	// want to accept the settings (e.g. if the TSL certificate in the settings
@@ -93,6 +100,8 @@ type Callbacks interface {
 	// its own telemetry to the destination specified in the settings.
 	// We currently support 3 types of agent's own telemetry: metrics, traces, logs.
 	// The agent can support any subset of these types.
+	// If the settings include a certificate, client.NewTLSConfig can be used to
+	// create the TLS config for the connection.
 	OnOwnTelemetryConnectionSettings(
 		ctx context.Context,
 		telemetryType OwnTelemetryType,
@@ -108,6 +117,8 @@ type Callbacks interface {
 	// want to accept the settings (e.g. if the TSL certificate in the settings
 	// cannot be verified). The returned error will be reported back to the server
 	// via StatusReport message (using ConnectionStatuses field).
+	// If the settings include a certificate, client.NewTLSConfig can be used to
+	// create the TLS config for the connection.
 	OnOtherConnectionSettings(
 		ctx context.Context,
 		name string,
@@ -123,9 +134,22 @@ type Callbacks interface {
 	// OnAgentPackageAvailable is called when the server has an agent package available
 	// for the agent.
 	// syncer can be used to initiate syncing the package from the server.
-	OnAgentPackageAvailable(addons *protobufs.AgentPackageAvailable, syncer AgentPackageSyncer) error
+	OnAgentPackageAvailable(
+		ctx context.Context,
+		packageAvailable *protobufs.AgentPackageAvailable,
+		syncer AgentPackageSyncer,
+	) error
 
 	// For all methods that accept a context parameter the caller may cancel the
 	// context if processing takes too long. In that case the method should return
 	// as soon as possible with an error.
+	//
+	// The methods that accept a context parameter are called asynchronously, so
+	// that they do not block receiving of the messages from the server. Only one
+	// call of each such method can be active at any time. If new data is received
+	// from the server while a call is active, only the most recently received
+	// data is remembered and the method is called with it once the active call
+	// returns. The connection settings offers are processed as a whole: the
+	// methods for a newer offer are called after the methods for the previous
+	// offer return.
 }
@@ -1,6 +1,7 @@
 package types
 
-type Logger interface {
-	Debugf(format string, v ...interface{})
-	Errorf(format string, v ...interface{})
-}
+import "github.com/open-telemetry/opamp-go/logging"
+
+// Logger is the printf-style logger interface accepted by client.New. Use
+// client.NewWithLogger for the leveled, structured logging.Logger.
+type Logger = logging.PrintfLogger
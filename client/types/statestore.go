@@ -0,0 +1,25 @@
+package types
+
+import "github.com/open-telemetry/opamp-go/protobufs"
+
+// State is the state of the client that needs to be preserved across restarts
+// of the Agent. See the corresponding fields of StartSettings for details.
+type State struct {
+	InstanceUid                     string
+	LastRemoteConfigHash            []byte
+	LastEffectiveConfig             *protobufs.EffectiveConfig
+	LastConnectionSettingsHash      []byte
+	LastServerProvidedAllAddonsHash []byte
+}
+
+// StateStore loads and saves the State. The client saves the state every time
+// it changes, e.g. when a remote config is applied or the addons are synced.
+type StateStore interface {
+	// Load returns the last saved state. Returns nil state and nil error if
+	// the state was never saved.
+	Load() (*State, error)
+
+	// Save replaces the saved state by the specified state. If Save fails the
+	// previously saved state must remain intact.
+	Save(state *State) error
+}
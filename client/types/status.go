@@ -0,0 +1,69 @@
+package types
+
+import "time"
+
+// ConnectionState is the state of the connection of the client to the server.
+type ConnectionState int
+
+const (
+	// StateConnecting means the client is trying to connect to the server.
+	StateConnecting ConnectionState = iota
+
+	// StateConnected means the client is connected to the server. When using
+	// the HTTP transport the client is considered connected while its requests
+	// succeed.
+	StateConnected
+
+	// StateBackoff means the last attempt to connect or to send failed and the
+	// client waits before trying again.
+	StateBackoff
+
+	// StateStopped means the client is not started or is stopped.
+	StateStopped
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateBackoff:
+		return "backoff"
+	case StateStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// Status describes the connection of the client to the server and the traffic
+// exchanged with the server.
+type Status struct {
+	State ConnectionState
+
+	// The URL of the server that the client is connected or connecting to.
+	ServerURL string
+
+	// The time when the current connection was established. Zero if the client
+	// is not connected.
+	ConnectedAt time.Time
+
+	// The number of times the client connected to the server again after the
+	// first successful connection.
+	ReconnectCount int64
+
+	// The last error that happened while connecting to the server or while
+	// exchanging messages with it. Nil if there were no errors.
+	LastError error
+
+	// The time until the next attempt to connect or to send if State is
+	// StateBackoff, zero otherwise.
+	NextRetryIn time.Duration
+
+	// The number of messages and bytes sent to the server and received from
+	// the server, across all connections.
+	MessagesSent     uint64
+	MessagesReceived uint64
+	BytesSent        uint64
+	BytesReceived    uint64
+}
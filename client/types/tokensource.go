@@ -0,0 +1,45 @@
+package types
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Token is an access token that the client uses to authenticate to the OpAMP
+// Server.
+type Token struct {
+	AccessToken string
+
+	// The type of the token, e.g. "Bearer". If empty "Bearer" is assumed.
+	TokenType string
+
+	// When the token expires. Zero means that the token does not expire.
+	Expiry time.Time
+}
+
+// AuthorizationHeader returns the value of the Authorization header that
+// carries the token.
+func (t *Token) AuthorizationHeader() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// TokenSource provides the access tokens that the client sends to the OpAMP
+// Server in the Authorization header. Implementations must be safe for
+// concurrent use.
+type TokenSource interface {
+	// Token returns a valid token. The client calls Token before each
+	// connection attempt (before each request when using HTTP transport), so
+	// implementations are expected to cache the token and to obtain a new one
+	// only when the cached one expires.
+	Token(ctx context.Context) (*Token, error)
+
+	// Invalidate discards the cached token, so that the next Token call
+	// obtains a new one. Called when the server rejects the token by
+	// responding with 401 Unauthorized.
+	Invalidate()
+}
@@ -4,19 +4,17 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
-	"math/rand"
 	"os"
 	"runtime"
 	"sort"
-	"time"
 
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/rawbytes"
-	"github.com/oklog/ulid/v2"
-	"github.com/open-telemetry/opamp-go/client/types"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/open-telemetry/opamp-go/client"
+	"github.com/open-telemetry/opamp-go/logging"
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
@@ -39,8 +37,14 @@ service:
       exporters: [otlp]
 `
 
+// The files where the agent keeps its state across restarts.
+const (
+	stateFile        = "agent-state.json"
+	remoteConfigFile = "agent-remote-config.pb"
+)
+
 type Agent struct {
-	logger types.Logger
+	logger logging.Logger
 
 	agentType    string
 	agentVersion string
@@ -48,8 +52,6 @@ type Agent struct {
 	effectiveConfig     string
 	effectiveConfigHash []byte
 
-	instanceId ulid.ULID
-
 	agentDescription *protobufs.AgentDescription
 
 	opampClient client.OpAMPClient
@@ -57,7 +59,7 @@ type Agent struct {
 	remoteConfigHash []byte
 }
 
-func NewAgent(logger types.Logger, agentType string, agentVersion string) *Agent {
+func NewAgent(logger logging.Logger, agentType string, agentVersion string) *Agent {
 	agent := &Agent{
 		effectiveConfig: localConfig,
 		logger:          logger,
@@ -65,13 +67,13 @@ func NewAgent(logger types.Logger, agentType string, agentVersion string) *Agent
 		agentVersion:    agentVersion,
 	}
 
-	agent.createAgentIdentity()
-	agent.logger.Debugf("Agent starting, id=%v, type=%s, version=%s.",
-		agent.instanceId.String(), agentType, agentVersion)
+	agent.createAgentDescription()
+	agent.logger.Info("Agent starting", logging.String("type", agentType), logging.String("version", agentVersion))
 
 	agent.loadLocalConfig()
+	agent.loadRemoteConfig()
 	if err := agent.start(); err != nil {
-		agent.logger.Errorf("Cannot start OpAMP client: %v", err)
+		agent.logger.Error("Cannot start OpAMP client", logging.Err(err))
 		return nil
 	}
 
@@ -79,45 +81,42 @@ func NewAgent(logger types.Logger, agentType string, agentVersion string) *Agent
 }
 
 func (agent *Agent) start() error {
-	agent.opampClient = client.New(agent.logger)
+	agent.opampClient = client.NewWithLogger(agent.logger)
 
 	settings := client.StartSettings{
 		OpAMPServerURL:   "ws://127.0.0.1:4320/v1/opamp",
-		InstanceUid:      agent.instanceId.String(),
 		AgentDescription: agent.agentDescription,
+		// The instance id and the hashes of what we received from the server
+		// are kept across restarts.
+		StateStore: client.NewFileStateStore(stateFile),
 		Callbacks: client.CallbacksStruct{
-			OnConnectFunc: func() {
-				agent.logger.Debugf("Connected to the server.")
+			OnConnectFunc: func(serverURL string) {
+				agent.logger.Info("Connected to the server", logging.ServerURL(serverURL))
 			},
-			OnConnectFailedFunc: func(err error) {
-				agent.logger.Errorf("Failed to connect to the server: %v", err)
+			OnConnectFailedFunc: func(serverURL string, err error) {
+				agent.logger.Warn("Failed to connect to the server", logging.ServerURL(serverURL), logging.Err(err))
 			},
 			OnErrorFunc: func(err *protobufs.ServerErrorResponse) {
-				agent.logger.Errorf("Server returned an error response: %v", err.ErrorMessage)
+				agent.logger.Error("Server returned an error response", logging.String("message", err.ErrorMessage))
 			},
 			OnRemoteConfigFunc: agent.onRemoteConfig,
 		},
-		LastRemoteConfigHash: agent.remoteConfigHash,
-		LastEffectiveConfig:  agent.composeEffectiveConfig(),
+		LastEffectiveConfig: agent.composeEffectiveConfig(),
 	}
 
-	agent.logger.Debugf("Starting OpAMP client...")
+	agent.logger.Debug("Starting OpAMP client...")
 
 	err := agent.opampClient.Start(settings)
 	if err != nil {
 		return err
 	}
 
-	agent.logger.Debugf("OpAMP Client started.")
+	agent.logger.Info("OpAMP Client started")
 
 	return nil
 }
 
-func (agent *Agent) createAgentIdentity() {
-	// Generate instance id.
-	entropy := ulid.Monotonic(rand.New(rand.NewSource(0)), 0)
-	agent.instanceId = ulid.MustNew(ulid.Timestamp(time.Now()), entropy)
-
+func (agent *Agent) createAgentDescription() {
 	hostname, _ := os.Hostname()
 
 	// Create agent description.
@@ -171,6 +170,37 @@ func (agent *Agent) loadLocalConfig() {
 	agent.effectiveConfigHash = hash[:]
 }
 
+// loadRemoteConfig applies the remote config saved by the previous run of the
+// agent, if any. The client reports the hash of this config to the server, so
+// the server does not send it again.
+func (agent *Agent) loadRemoteConfig() {
+	data, err := os.ReadFile(remoteConfigFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			agent.logger.Error("Cannot read saved remote config", logging.Err(err))
+		}
+		return
+	}
+	config := &protobufs.AgentRemoteConfig{}
+	if err := proto.Unmarshal(data, config); err != nil {
+		agent.logger.Error("Cannot decode saved remote config", logging.Err(err))
+		return
+	}
+	if err := agent.applyRemoteConfig(config); err != nil {
+		agent.logger.Error("Cannot apply saved remote config", logging.Err(err))
+	}
+}
+
+// saveRemoteConfig saves the applied remote config so that it can be applied
+// again after restart.
+func (agent *Agent) saveRemoteConfig(config *protobufs.AgentRemoteConfig) error {
+	data, err := proto.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(remoteConfigFile, data, 0600)
+}
+
 func (agent *Agent) composeEffectiveConfig() *protobufs.EffectiveConfig {
 	return &protobufs.EffectiveConfig{
 		Hash: agent.effectiveConfigHash,
@@ -190,6 +220,11 @@ func (agent *Agent) onRemoteConfig(
 	if err != nil {
 		return nil, err
 	}
+	if config != nil {
+		if err := agent.saveRemoteConfig(config); err != nil {
+			return nil, err
+		}
+	}
 	return agent.composeEffectiveConfig(), nil
 }
 
@@ -219,7 +254,7 @@ func (agent *Agent) applyRemoteConfig(config *protobufs.AgentRemoteConfig) error
 		return nil
 	}
 
-	agent.logger.Debugf("Received remote config from server, hash=%x.", config.ConfigHash)
+	agent.logger.Info("Received remote config from server", logging.String("hash", fmt.Sprintf("%x", config.ConfigHash)))
 
 	// Begin with local config. We will later merge received configs on top of it.
 	var k = koanf.New(".")
@@ -272,7 +307,7 @@ func (agent *Agent) applyRemoteConfig(config *protobufs.AgentRemoteConfig) error
 
 	newEffectiveConfig := string(effectiveConfigBytes)
 	if agent.effectiveConfig != newEffectiveConfig {
-		agent.logger.Debugf("Effective config changed. Need to report to server.")
+		agent.logger.Debug("Effective config changed. Need to report to server.")
 		agent.effectiveConfig = newEffectiveConfig
 		hash := sha256.Sum256(effectiveConfigBytes)
 		agent.effectiveConfigHash = hash[:]
@@ -284,7 +319,7 @@ func (agent *Agent) applyRemoteConfig(config *protobufs.AgentRemoteConfig) error
 }
 
 func (agent *Agent) Shutdown() {
-	agent.logger.Debugf("Agent shutting down...")
+	agent.logger.Info("Agent shutting down...")
 	if agent.opampClient != nil {
 		agent.opampClient.Stop(context.Background())
 	}
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 
 	"github.com/open-telemetry/opamp-go/internal/examples/agent/agent"
+	"github.com/open-telemetry/opamp-go/logging"
 )
 
 func main() {
@@ -18,7 +19,7 @@ func main() {
 
 	flag.Parse()
 
-	agent := agent.NewAgent(&agent.Logger{log.Default()}, agentType, agentVersion)
+	agent := agent.NewAgent(logging.NewStdLogger(log.Default()), agentType, agentVersion)
 
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
@@ -130,6 +130,11 @@ func (agent *Agent) updateStatusField(newStatus *protobufs.StatusReport) (needCa
 		if newStatus.RemoteConfigStatus != nil {
 			agent.Status.RemoteConfigStatus = newStatus.RemoteConfigStatus
 		}
+
+		// Update connection statuses if they are provided.
+		if newStatus.ConnectionStatuses != nil {
+			agent.Status.ConnectionStatuses = newStatus.ConnectionStatuses
+		}
 	}
 
 	return needCalculateConfig
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultPingInterval is the default interval at which pings are sent over
+	// WebSocket connections.
+	DefaultPingInterval = 30 * time.Second
+
+	// DefaultPongTimeout is the default time to wait for a pong (or any other
+	// message) after the ping interval elapses before the connection is
+	// considered dead.
+	DefaultPongTimeout = 10 * time.Second
+)
+
+// KeepaliveSettings returns the effective ping interval and pong timeout given
+// the configured values. Zero values are replaced by the defaults. Returns
+// enabled=false if keepalive is disabled by a negative ping interval.
+func KeepaliveSettings(pingInterval, pongTimeout time.Duration) (
+	interval time.Duration, timeout time.Duration, enabled bool,
+) {
+	if pingInterval < 0 {
+		return 0, 0, false
+	}
+	if pingInterval == 0 {
+		pingInterval = DefaultPingInterval
+	}
+	if pongTimeout <= 0 {
+		pongTimeout = DefaultPongTimeout
+	}
+	return pingInterval, pongTimeout, true
+}
+
+// WSKeepalive periodically pings the peer of a WebSocket connection and makes
+// reads from the connection fail if nothing is received from the peer for
+// longer than the ping interval plus the pong timeout. This detects half-open
+// connections that would otherwise never fail.
+type WSKeepalive struct {
+	conn         *websocket.Conn
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	enabled      bool
+
+	// Closed to stop pinging.
+	stop chan struct{}
+	// Closed when pinging is stopped.
+	stopped chan struct{}
+}
+
+// StartWSKeepalive begins pinging the peer of the connection in the background.
+// ExtendReadDeadline must be called before each read from the connection and
+// Stop must be called when the connection is no longer used.
+// A negative pingInterval disables keepalive, zero values select the defaults.
+func StartWSKeepalive(conn *websocket.Conn, pingInterval, pongTimeout time.Duration) *WSKeepalive {
+	k := &WSKeepalive{
+		conn:    conn,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	k.pingInterval, k.pongTimeout, k.enabled = KeepaliveSettings(pingInterval, pongTimeout)
+
+	if !k.enabled {
+		close(k.stopped)
+		return k
+	}
+
+	conn.SetPongHandler(func(string) error { return k.ExtendReadDeadline() })
+	go k.run()
+	return k
+}
+
+// ExtendReadDeadline sets the read deadline of the connection to the time by
+// which the next message or pong must be received from the peer.
+func (k *WSKeepalive) ExtendReadDeadline() error {
+	if !k.enabled {
+		return nil
+	}
+	return k.conn.SetReadDeadline(time.Now().Add(k.pingInterval + k.pongTimeout))
+}
+
+// Stop pinging and wait until the background goroutine exits. Does not close
+// the connection.
+func (k *WSKeepalive) Stop() {
+	if k.enabled {
+		close(k.stop)
+	}
+	<-k.stopped
+}
+
+func (k *WSKeepalive) run() {
+	defer close(k.stopped)
+
+	ticker := time.NewTicker(k.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			deadline := time.Now().Add(k.pongTimeout)
+			if err := k.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				// Cannot ping, the connection is broken. Close it to make the
+				// reading side fail.
+				k.conn.Close()
+				return
+			}
+
+		case <-k.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package logging defines the leveled, structured logger that is used by the
+// OpAMP client and server, and adapters for the common loggers.
+package logging
+
+import "strconv"
+
+// Logger is a leveled logger that accepts key/value fields in addition to the
+// message. Implementations must be safe for concurrent use.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+
+	// With returns a Logger that adds the specified fields to every message.
+	With(fields ...Field) Logger
+}
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level in upper case, e.g. "INFO".
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// The keys of the fields that the client and the server use.
+const (
+	KeyInstanceUid = "instance_uid"
+	KeyRemoteAddr  = "remote_addr"
+	KeyServerURL   = "server_url"
+	KeyError       = "error"
+)
+
+// Field is a key/value pair attached to a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a field with a string value.
+func String(key string, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Any returns a field with an arbitrary value.
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err returns a field with the specified error, keyed by KeyError.
+func Err(err error) Field {
+	return Field{Key: KeyError, Value: err}
+}
+
+// InstanceUid returns a field with the instance UID of an agent.
+func InstanceUid(instanceUid string) Field {
+	return String(KeyInstanceUid, instanceUid)
+}
+
+// RemoteAddr returns a field with the network address of the peer.
+func RemoteAddr(addr string) Field {
+	return String(KeyRemoteAddr, addr)
+}
+
+// ServerURL returns a field with the URL of the OpAMP server.
+func ServerURL(url string) Field {
+	return String(KeyServerURL, url)
+}
+
+// NopLogger is a Logger that discards all messages.
+type NopLogger struct{}
+
+var _ Logger = NopLogger{}
+
+func (NopLogger) Debug(msg string, fields ...Field) {}
+func (NopLogger) Info(msg string, fields ...Field)  {}
+func (NopLogger) Warn(msg string, fields ...Field)  {}
+func (NopLogger) Error(msg string, fields ...Field) {}
+
+func (l NopLogger) With(fields ...Field) Logger {
+	return l
+}
+
+// withFields returns the fields followed by more fields. Never modifies the
+// array that backs fields.
+func withFields(fields []Field, more []Field) []Field {
+	if len(more) == 0 {
+		return fields
+	}
+	all := make([]Field, 0, len(fields)+len(more))
+	all = append(all, fields...)
+	return append(all, more...)
+}
@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+
+	logger.Info("Agent connected", RemoteAddr("127.0.0.1:1234"), InstanceUid("abc"))
+	logger.Warn("Cannot send", Err(errors.New("broken pipe")))
+	logger.With(ServerURL("ws://server")).Debug("Disconnected", String("reason", ""))
+	logger.Error("Cannot decode", Any("size", 42))
+
+	assert.EqualValues(
+		t,
+		"INFO Agent connected remote_addr=127.0.0.1:1234 instance_uid=abc\n"+
+			"WARN Cannot send error=\"broken pipe\"\n"+
+			"DEBUG Disconnected server_url=ws://server reason=\"\"\n"+
+			"ERROR Cannot decode size=42\n",
+		buf.String(),
+	)
+}
+
+func TestLeveledStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLeveledStdLogger(log.New(&buf, "", 0), LevelWarn).With(InstanceUid("abc"))
+
+	logger.Debug("Debug message")
+	logger.Info("Info message")
+	logger.Warn("Warn message")
+	logger.Error("Error message")
+
+	assert.EqualValues(
+		t,
+		"WARN Warn message instance_uid=abc\n"+
+			"ERROR Error message instance_uid=abc\n",
+		buf.String(),
+	)
+}
+
+type printfLogger struct {
+	debug []string
+	error []string
+}
+
+func (l *printfLogger) Debugf(format string, v ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, v...))
+}
+
+func (l *printfLogger) Errorf(format string, v ...interface{}) {
+	l.error = append(l.error, fmt.Sprintf(format, v...))
+}
+
+func TestFromPrintf(t *testing.T) {
+	printf := &printfLogger{}
+	logger := FromPrintf(printf).With(InstanceUid("abc"))
+
+	logger.Debug("Debug message")
+	logger.Info("Info message 100%", String("key", "value"))
+	logger.Warn("Warn message")
+	logger.Error("Error message", Err(errors.New("failed")))
+
+	assert.EqualValues(
+		t,
+		[]string{"Debug message instance_uid=abc", "Info message 100% instance_uid=abc key=value"},
+		printf.debug,
+	)
+	assert.EqualValues(
+		t,
+		[]string{"Warn message instance_uid=abc", "Error message instance_uid=abc error=failed"},
+		printf.error,
+	)
+}
+
+func TestWithDoesNotShareFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0)).With(String("a", "1"))
+
+	// Both loggers derived from the same logger must have their own fields.
+	first := logger.With(String("b", "2"))
+	second := logger.With(String("c", "3"))
+	first.Info("first")
+	second.Info("second")
+
+	assert.EqualValues(t, "INFO first a=1 b=2\nINFO second a=1 c=3\n", buf.String())
+}
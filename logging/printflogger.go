@@ -0,0 +1,51 @@
+package logging
+
+import "strings"
+
+// PrintfLogger is the printf-style logger interface accepted by client.New and
+// server.New.
+type PrintfLogger interface {
+	Debugf(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+// printfShim adapts a PrintfLogger to Logger.
+type printfShim struct {
+	logger PrintfLogger
+	fields []Field
+}
+
+// FromPrintf returns a Logger that writes to the specified PrintfLogger. The
+// Debug and Info messages are written with Debugf, the Warn and Error messages
+// with Errorf. The fields are appended to the message in key=value form.
+func FromPrintf(logger PrintfLogger) Logger {
+	return &printfShim{logger: logger}
+}
+
+func (l *printfShim) Debug(msg string, fields ...Field) {
+	l.logger.Debugf("%s", l.format(msg, fields))
+}
+
+func (l *printfShim) Info(msg string, fields ...Field) {
+	l.logger.Debugf("%s", l.format(msg, fields))
+}
+
+func (l *printfShim) Warn(msg string, fields ...Field) {
+	l.logger.Errorf("%s", l.format(msg, fields))
+}
+
+func (l *printfShim) Error(msg string, fields ...Field) {
+	l.logger.Errorf("%s", l.format(msg, fields))
+}
+
+func (l *printfShim) With(fields ...Field) Logger {
+	return &printfShim{logger: l.logger, fields: withFields(l.fields, fields)}
+}
+
+func (l *printfShim) format(msg string, fields []Field) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	writeFields(&b, l.fields)
+	writeFields(&b, fields)
+	return b.String()
+}
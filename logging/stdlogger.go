@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// stdLogger writes the messages to a log.Logger from the standard library.
+type stdLogger struct {
+	logger   *log.Logger
+	minLevel Level
+	fields   []Field
+}
+
+// NewStdLogger returns a Logger that writes the messages of all levels to the
+// specified log.Logger, or to log.Default() if logger is nil. The messages are
+// written as the level followed by the message and the fields in key=value
+// form, e.g.:
+//
+//	INFO Agent connected remote_addr=127.0.0.1:50124
+func NewStdLogger(logger *log.Logger) Logger {
+	return NewLeveledStdLogger(logger, LevelDebug)
+}
+
+// NewLeveledStdLogger is the same as NewStdLogger, except that the messages
+// below minLevel are discarded, e.g. LevelInfo discards the Debug messages.
+func NewLeveledStdLogger(logger *log.Logger, minLevel Level) Logger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &stdLogger{logger: logger, minLevel: minLevel}
+}
+
+func (l *stdLogger) Debug(msg string, fields ...Field) {
+	l.print(LevelDebug, msg, fields)
+}
+
+func (l *stdLogger) Info(msg string, fields ...Field) {
+	l.print(LevelInfo, msg, fields)
+}
+
+func (l *stdLogger) Warn(msg string, fields ...Field) {
+	l.print(LevelWarn, msg, fields)
+}
+
+func (l *stdLogger) Error(msg string, fields ...Field) {
+	l.print(LevelError, msg, fields)
+}
+
+func (l *stdLogger) With(fields ...Field) Logger {
+	return &stdLogger{logger: l.logger, minLevel: l.minLevel, fields: withFields(l.fields, fields)}
+}
+
+func (l *stdLogger) print(level Level, msg string, fields []Field) {
+	if level < l.minLevel {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	writeFields(&b, l.fields)
+	writeFields(&b, fields)
+	l.logger.Print(b.String())
+}
+
+func writeFields(b *strings.Builder, fields []Field) {
+	for _, field := range fields {
+		b.WriteByte(' ')
+		b.WriteString(field.Key)
+		b.WriteByte('=')
+		b.WriteString(formatValue(field.Value))
+	}
+}
+
+// formatValue formats the value, quoting it if it would be ambiguous otherwise.
+func formatValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
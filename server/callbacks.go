@@ -11,6 +11,7 @@ type CallbacksStruct struct {
 	OnConnectingFunc      func(request *http.Request) types.ConnectionResponse
 	OnConnectedFunc       func(conn types.Connection)
 	OnMessageFunc         func(conn types.Connection, message *protobufs.AgentToServer)
+	OnAgentDisconnectFunc func(conn types.Connection, instanceUid string)
 	OnConnectionCloseFunc func(conn types.Connection)
 }
 
@@ -35,6 +36,12 @@ func (c CallbacksStruct) OnMessage(conn types.Connection, message *protobufs.Age
 	}
 }
 
+func (c CallbacksStruct) OnAgentDisconnect(conn types.Connection, instanceUid string) {
+	if c.OnAgentDisconnectFunc != nil {
+		c.OnAgentDisconnectFunc(conn, instanceUid)
+	}
+}
+
 func (c CallbacksStruct) OnConnectionClose(conn types.Connection) {
 	if c.OnConnectionCloseFunc != nil {
 		c.OnConnectionCloseFunc(conn)
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"github.com/open-telemetry/opamp-go/server/types"
+)
+
+// httpConnection is a synthesized connection of an agent that uses plain HTTP
+// transport. All requests of the same agent (identified by the instance UID)
+// share the same httpConnection until the agent stops making requests for
+// longer than the session timeout.
+type httpConnection struct {
+	instanceUid string
+
+	// Serializes handling of the requests of this connection so that the
+	// callbacks are not called concurrently for the same connection.
+	requestMutex sync.Mutex
+
+	// True if OnConnected was called for this connection. Protected by requestMutex.
+	connected bool
+
+	// The writer of the response to the request that is currently being handled.
+	// Nil if no request is being handled or if the response is already written.
+	responseWriter http.ResponseWriter
+
+	// The message to send in the response to the next request. A message that
+	// is sent when there is no response to write to replaces the pending one.
+	pending *protobufs.ServerToAgent
+
+	// Protects response and pending.
+	sendMutex sync.Mutex
+
+	// The following fields are protected by server's httpSessionsMutex.
+
+	// The number of requests of this connection that are being handled.
+	activeRequests int
+	// The time when the last request was finished.
+	lastActivity time.Time
+	// Fires when the session times out.
+	timer *time.Timer
+}
+
+var _ types.Connection = (*httpConnection)(nil)
+
+// Send writes the message as the HTTP response of the request that is currently
+// being handled. If there is no such request (or the response is already
+// written) the message is sent in the response to the next request from the
+// agent. If Send is called multiple times before the next request only the
+// last message is sent, the previous ones are discarded. Merging the messages
+// would produce a message that was never sent, e.g. with the stale keys of a
+// remote config map.
+func (c *httpConnection) Send(_ context.Context, message *protobufs.ServerToAgent) error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	// The message may be written later, after the caller modifies it.
+	c.pending = proto.Clone(message).(*protobufs.ServerToAgent)
+
+	return c.flush()
+}
+
+// beginResponse makes the subsequent Send calls write to the specified response.
+func (c *httpConnection) beginResponse(w http.ResponseWriter) {
+	c.sendMutex.Lock()
+	c.responseWriter = w
+	c.sendMutex.Unlock()
+}
+
+// endResponse writes the response if it was not written during the handling
+// of the request.
+func (c *httpConnection) endResponse() error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	if c.responseWriter == nil {
+		return nil
+	}
+
+	if c.pending == nil {
+		// Nothing to send, respond with an empty message.
+		c.pending = &protobufs.ServerToAgent{InstanceUid: c.instanceUid}
+	}
+	return c.flush()
+}
+
+// flush writes the pending message to the current response if there is one.
+// Must be called with sendMutex held.
+func (c *httpConnection) flush() error {
+	if c.responseWriter == nil {
+		return nil
+	}
+	w := c.responseWriter
+	c.responseWriter = nil
+
+	bytes, err := proto.Marshal(c.pending)
+	if err != nil {
+		return err
+	}
+	c.pending = nil
+
+	w.Header().Set(headerContentType, contentTypeProtobuf)
+	_, err = w.Write(bytes)
+	return err
+}
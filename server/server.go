@@ -3,7 +3,9 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/open-telemetry/opamp-go/server/types"
 )
@@ -11,14 +13,42 @@ import (
 type Settings struct {
 	// Callbacks that the server will call after successful Attach/Start.
 	Callbacks types.Callbacks
+
+	// HTTPSessionTimeout is the time after which the connection of an agent
+	// that uses plain HTTP transport is considered closed if the agent makes
+	// no requests. If zero the default of 90 seconds is used.
+	HTTPSessionTimeout time.Duration
+
+	// The maximum size in bytes of the body of the requests made by the agents
+	// that use plain HTTP transport. The server responds with 413 Request
+	// Entity Too Large to larger requests. If zero the default of 16 MiB is
+	// used.
+	MaxHTTPRequestBodySize int64
+
+	// The interval at which the server pings the agents connected over
+	// WebSocket. If nothing (not even a pong) is received from an agent for
+	// PingInterval+PongTimeout the connection is closed and OnConnectionClose
+	// is called. If 0 the default of 30 seconds is used. A negative value
+	// disables pinging.
+	PingInterval time.Duration
+
+	// PongTimeout is the time to wait for a pong in addition to PingInterval.
+	// If 0 the default of 10 seconds is used.
+	PongTimeout time.Duration
 }
 
 type StartSettings struct {
 	Settings
 
 	// ListenEndpoint specifies the endpoint to listen on, e.g. "127.0.0.1:4320"
+	// or "unix:///run/opamp.sock" to listen on a Unix domain socket.
 	ListenEndpoint string
 
+	// Listener to accept the connections on instead of listening on
+	// ListenEndpoint, e.g. a listener inherited from the parent process. The
+	// server closes it on Stop().
+	Listener net.Listener
+
 	// ListenPath specifies the URL path on which to accept the OpAMP connections
 	// If this is empty string then Start() will use the default "/v1/opamp" path.
 	ListenPath string
@@ -3,14 +3,19 @@ package server
 import (
 	"context"
 	"errors"
+	"io"
+	"mime"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"google.golang.org/protobuf/proto"
 
-	"github.com/open-telemetry/opamp-go/client/types"
 	"github.com/open-telemetry/opamp-go/internal"
+	"github.com/open-telemetry/opamp-go/logging"
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
@@ -20,8 +25,21 @@ var (
 
 const defaultOpAMPPath = "/v1/opamp"
 
+const defaultHTTPSessionTimeout = 90 * time.Second
+
+const defaultMaxHTTPRequestBodySize = 16 << 20
+
+// unixEndpointPrefix is the prefix of the listen endpoints that are Unix
+// domain sockets.
+const unixEndpointPrefix = "unix://"
+
+const (
+	headerContentType   = "Content-Type"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
 type server struct {
-	logger   types.Logger
+	logger   logging.Logger
 	settings Settings
 
 	// Upgrader to use to upgrade HTTP to WebSocket.
@@ -30,13 +48,34 @@ type server struct {
 	// The listening HTTP Server after successful Start() call. Nil if Start()
 	// is not called or was not successful.
 	httpServer *http.Server
+
+	// Connections of the agents that use plain HTTP transport, keyed by
+	// instance UID.
+	httpSessions      map[string]*httpConnection
+	httpSessionsMutex sync.Mutex
 }
 
 var _ OpAMPServer = (*server)(nil)
 
-func New(logger types.Logger) *server {
+// New creates a server that writes the log messages to the specified
+// printf-style logger. If the logger also implements logging.Logger the
+// messages are written to it as structured messages. Nothing is logged if the
+// logger is nil.
+func New(logger logging.PrintfLogger) *server {
 	if logger == nil {
-		logger = &internal.NopLogger{}
+		return NewWithLogger(nil)
+	}
+	if structured, ok := logger.(logging.Logger); ok {
+		return NewWithLogger(structured)
+	}
+	return NewWithLogger(logging.FromPrintf(logger))
+}
+
+// NewWithLogger creates a server that writes the log messages to the specified
+// structured logger. Nothing is logged if the logger is nil.
+func NewWithLogger(logger logging.Logger) *server {
+	if logger == nil {
+		logger = logging.NopLogger{}
 	}
 
 	return &server{logger: logger}
@@ -44,7 +83,14 @@ func New(logger types.Logger) *server {
 
 func (s *server) Attach(settings Settings) (HTTPHandlerFunc, error) {
 	s.settings = settings
+	if s.settings.HTTPSessionTimeout == 0 {
+		s.settings.HTTPSessionTimeout = defaultHTTPSessionTimeout
+	}
+	if s.settings.MaxHTTPRequestBodySize == 0 {
+		s.settings.MaxHTTPRequestBodySize = defaultMaxHTTPRequestBodySize
+	}
 	s.wsUpgrader = websocket.Upgrader{}
+	s.httpSessions = map[string]*httpConnection{}
 	return s.httpHandler, nil
 }
 
@@ -83,6 +129,7 @@ func (s *server) Start(settings StartSettings) error {
 			listenAddr = ":https"
 		}
 		err = s.startHttpServer(
+			settings.Listener,
 			listenAddr,
 			func(l net.Listener) error { return hs.ServeTLS(l, "", "") },
 		)
@@ -91,6 +138,7 @@ func (s *server) Start(settings StartSettings) error {
 			listenAddr = ":http"
 		}
 		err = s.startHttpServer(
+			settings.Listener,
 			listenAddr,
 			func(l net.Listener) error { return hs.Serve(l) },
 		)
@@ -98,33 +146,51 @@ func (s *server) Start(settings StartSettings) error {
 	return err
 }
 
-func (s *server) startHttpServer(listenAddr string, serveFunc func(l net.Listener) error) error {
-	// If the listen address is not specified use the default.
-	ln, err := net.Listen("tcp", listenAddr)
-	if err != nil {
-		return err
+func (s *server) startHttpServer(
+	ln net.Listener,
+	listenAddr string,
+	serveFunc func(l net.Listener) error,
+) error {
+	// Listen on the listen address unless the listener is supplied.
+	if ln == nil {
+		var err error
+		ln, err = listen(listenAddr)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Begin serving connections in the background.
 	go func() {
-		err = serveFunc(ln)
+		err := serveFunc(ln)
 
 		// ErrServerClosed is expected after successful Stop(), so we won't log that
 		// particular error.
 		if err != nil && err != http.ErrServerClosed {
-			s.logger.Errorf("Error running HTTP Server: %v", err)
+			s.logger.Error("Error running HTTP Server", logging.Err(err))
 		}
 	}()
 
 	return nil
 }
 
+// listen listens on the endpoint, which is either a TCP address or a
+// "unix://" URL of a Unix domain socket.
+func listen(endpoint string) (net.Listener, error) {
+	if strings.HasPrefix(endpoint, unixEndpointPrefix) {
+		return net.Listen("unix", strings.TrimPrefix(endpoint, unixEndpointPrefix))
+	}
+	return net.Listen("tcp", endpoint)
+}
+
 func (s *server) Stop(ctx context.Context) error {
 	if s.httpServer != nil {
 		defer func() { s.httpServer = nil }()
 		// This stops accepting new connections. TODO: close existing
 		// connections and wait them to be terminated.
-		return s.httpServer.Shutdown(ctx)
+		err := s.httpServer.Shutdown(ctx)
+		s.closeHTTPSessions()
+		return err
 	}
 	return nil
 }
@@ -143,10 +209,19 @@ func (s *server) httpHandler(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	if req.Method == http.MethodPost {
+		// Plain HTTP transport, the request carries an AgentToServer message.
+		s.handlePlainHTTPRequest(w, req)
+		return
+	}
+
 	// HTTP connection is accepted. Upgrade it to WebSocket.
 	conn, err := s.wsUpgrader.Upgrade(w, req, nil)
 	if err != nil {
-		s.logger.Errorf("Cannot upgrade HTTP connection to WebSocket: %v", err)
+		s.logger.Warn(
+			"Cannot upgrade HTTP connection to WebSocket",
+			logging.RemoteAddr(req.RemoteAddr), logging.Err(err),
+		)
 		return
 	}
 
@@ -157,6 +232,11 @@ func (s *server) httpHandler(w http.ResponseWriter, req *http.Request) {
 
 func (s *server) handleWSConnection(wsConn *websocket.Conn) {
 	agentConn := connection{wsConn: wsConn}
+	logger := s.logger.With(logging.RemoteAddr(wsConn.RemoteAddr().String()))
+	logger.Info("Agent connected")
+
+	// The instance UID of the agent, known after the first message.
+	var instanceUid string
 
 	defer func() {
 		// Close the connection when all is done.
@@ -171,21 +251,32 @@ func (s *server) handleWSConnection(wsConn *websocket.Conn) {
 		s.settings.Callbacks.OnConnected(agentConn)
 	}
 
+	// Begin pinging the agent to detect if the connection is lost.
+	keepalive := internal.StartWSKeepalive(wsConn, s.settings.PingInterval, s.settings.PongTimeout)
+	defer keepalive.Stop()
+
 	// Loop until fail to read from the WebSocket connection.
 	for {
+		// The agent must send something (at least a pong) before the deadline,
+		// otherwise the connection is considered dead.
+		if err := keepalive.ExtendReadDeadline(); err != nil {
+			logger.Error("Cannot set read deadline", logging.Err(err))
+			break
+		}
+
 		// Block until the next message can be read.
 		mt, bytes, err := wsConn.ReadMessage()
 		if err != nil {
 			if !websocket.IsUnexpectedCloseError(err) {
-				s.logger.Errorf("Cannot read a message from WebSocket: %v", err)
+				logger.Warn("Cannot read a message from WebSocket", logging.Err(err))
 				break
 			}
 			// This is a normal closing of the WebSocket connection.
-			s.logger.Debugf("Agent disconnected: %v", err)
+			logger.Info("Agent disconnected", logging.Err(err))
 			break
 		}
 		if mt != websocket.BinaryMessage {
-			s.logger.Errorf("Received unexpected message type from WebSocket: %v", mt)
+			logger.Warn("Received unexpected message type from WebSocket", logging.Any("message_type", mt))
 			continue
 		}
 
@@ -193,12 +284,189 @@ func (s *server) handleWSConnection(wsConn *websocket.Conn) {
 		var request protobufs.AgentToServer
 		err = proto.Unmarshal(bytes, &request)
 		if err != nil {
-			s.logger.Errorf("Cannot decode message from WebSocket: %v", err)
+			logger.Warn("Cannot decode message from WebSocket", logging.Err(err))
 			continue
 		}
+		if instanceUid == "" && request.InstanceUid != "" {
+			instanceUid = request.InstanceUid
+			logger = logger.With(logging.InstanceUid(instanceUid))
+		}
 
 		if s.settings.Callbacks != nil {
 			s.settings.Callbacks.OnMessage(agentConn, &request)
+			if request.AgentDisconnect != nil {
+				s.settings.Callbacks.OnAgentDisconnect(agentConn, request.InstanceUid)
+			}
+		}
+	}
+}
+
+func (s *server) handlePlainHTTPRequest(w http.ResponseWriter, req *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(headerContentType))
+	if err != nil || mediaType != contentTypeProtobuf {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	logger := s.logger.With(logging.RemoteAddr(req.RemoteAddr))
+
+	// Read one byte more than the limit to detect that the body is too large.
+	maxSize := s.settings.MaxHTTPRequestBodySize
+	bytes, err := io.ReadAll(io.LimitReader(req.Body, maxSize+1))
+	if err != nil {
+		logger.Warn("Cannot read HTTP request body", logging.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if int64(len(bytes)) > maxSize {
+		logger.Warn("HTTP request body is too large", logging.Any("limit", maxSize))
+		// The rest of the body is not read, the connection cannot be reused.
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Decode the body as a Protobuf message.
+	var request protobufs.AgentToServer
+	err = proto.Unmarshal(bytes, &request)
+	if err != nil {
+		logger.Warn("Cannot decode message from HTTP request", logging.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if request.InstanceUid == "" {
+		// The session of the agent is identified by the instance UID.
+		logger.Warn("Message from HTTP request has no instance UID")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	logger = logger.With(logging.InstanceUid(request.InstanceUid))
+
+	agentConn := s.beginHTTPRequest(request.InstanceUid)
+	s.handleHTTPMessage(w, logger, agentConn, &request)
+	s.endHTTPRequest(agentConn)
+
+	if request.AgentDisconnect != nil {
+		// The agent is going away, end its session.
+		s.closeHTTPSession(agentConn)
+	}
+}
+
+func (s *server) handleHTTPMessage(
+	w http.ResponseWriter,
+	logger logging.Logger,
+	agentConn *httpConnection,
+	request *protobufs.AgentToServer,
+) {
+	agentConn.requestMutex.Lock()
+	defer agentConn.requestMutex.Unlock()
+
+	if !agentConn.connected {
+		logger.Info("Agent connected")
+		if s.settings.Callbacks != nil {
+			s.settings.Callbacks.OnConnected(agentConn)
+		}
+	}
+	agentConn.connected = true
+
+	agentConn.beginResponse(w)
+	if s.settings.Callbacks != nil {
+		s.settings.Callbacks.OnMessage(agentConn, request)
+		if request.AgentDisconnect != nil {
+			s.settings.Callbacks.OnAgentDisconnect(agentConn, request.InstanceUid)
+		}
+	}
+	if err := agentConn.endResponse(); err != nil {
+		logger.Warn("Cannot write HTTP response", logging.Err(err))
+	}
+}
+
+// beginHTTPRequest returns the connection of the agent with the specified
+// instance UID, creating it if this is the first request of the agent.
+func (s *server) beginHTTPRequest(instanceUid string) *httpConnection {
+	s.httpSessionsMutex.Lock()
+	defer s.httpSessionsMutex.Unlock()
+
+	conn := s.httpSessions[instanceUid]
+	if conn == nil {
+		conn = &httpConnection{instanceUid: instanceUid}
+		conn.timer = time.AfterFunc(s.settings.HTTPSessionTimeout, func() { s.expireHTTPSession(conn) })
+		s.httpSessions[instanceUid] = conn
+	}
+	conn.activeRequests++
+	conn.timer.Stop()
+	return conn
+}
+
+// endHTTPRequest restarts the session timeout of the connection once it has
+// no active requests.
+func (s *server) endHTTPRequest(conn *httpConnection) {
+	s.httpSessionsMutex.Lock()
+	defer s.httpSessionsMutex.Unlock()
+
+	conn.activeRequests--
+	conn.lastActivity = time.Now()
+	if conn.activeRequests == 0 {
+		conn.timer.Reset(s.settings.HTTPSessionTimeout)
+	}
+}
+
+// expireHTTPSession closes the connection if the agent made no requests during
+// the session timeout.
+func (s *server) expireHTTPSession(conn *httpConnection) {
+	s.httpSessionsMutex.Lock()
+	if s.httpSessions[conn.instanceUid] != conn ||
+		conn.activeRequests > 0 ||
+		time.Since(conn.lastActivity) < s.settings.HTTPSessionTimeout {
+		// The connection is already closed or is active again.
+		s.httpSessionsMutex.Unlock()
+		return
+	}
+	delete(s.httpSessions, conn.instanceUid)
+	s.httpSessionsMutex.Unlock()
+
+	s.closeHTTPConnection(conn)
+}
+
+// closeHTTPSession closes the connection unless it was already closed.
+func (s *server) closeHTTPSession(conn *httpConnection) {
+	s.httpSessionsMutex.Lock()
+	if s.httpSessions[conn.instanceUid] != conn {
+		// Already closed.
+		s.httpSessionsMutex.Unlock()
+		return
+	}
+	delete(s.httpSessions, conn.instanceUid)
+	conn.timer.Stop()
+	s.httpSessionsMutex.Unlock()
+
+	s.closeHTTPConnection(conn)
+}
+
+// closeHTTPSessions closes the connections of all agents that use plain HTTP transport.
+func (s *server) closeHTTPSessions() {
+	s.httpSessionsMutex.Lock()
+	sessions := s.httpSessions
+	s.httpSessions = map[string]*httpConnection{}
+	for _, conn := range sessions {
+		conn.timer.Stop()
+	}
+	s.httpSessionsMutex.Unlock()
+
+	for _, conn := range sessions {
+		s.closeHTTPConnection(conn)
+	}
+}
+
+func (s *server) closeHTTPConnection(conn *httpConnection) {
+	conn.requestMutex.Lock()
+	defer conn.requestMutex.Unlock()
+
+	if conn.connected {
+		s.logger.Info("Agent disconnected", logging.InstanceUid(conn.instanceUid))
+		if s.settings.Callbacks != nil {
+			s.settings.Callbacks.OnConnectionClose(conn)
 		}
 	}
+	conn.connected = false
 }
@@ -1,16 +1,24 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
-	sharedinternal "github.com/open-telemetry/opamp-go/internal"
 	"github.com/open-telemetry/opamp-go/internal/testhelpers"
+	"github.com/open-telemetry/opamp-go/logging"
 	"github.com/open-telemetry/opamp-go/protobufs"
 	"github.com/open-telemetry/opamp-go/server/types"
 	"github.com/stretchr/testify/assert"
@@ -19,7 +27,7 @@ import (
 )
 
 func startServer(t *testing.T, settings *StartSettings) *server {
-	srv := New(&sharedinternal.NopLogger{})
+	srv := NewWithLogger(logging.NopLogger{})
 	require.NotNil(t, srv)
 	if settings.ListenEndpoint == "" {
 		// Find an avaiable port to listne on.
@@ -35,8 +43,20 @@ func startServer(t *testing.T, settings *StartSettings) *server {
 }
 
 func dialClient(serverSettings *StartSettings) (*websocket.Conn, *http.Response, error) {
-	srvUrl := "ws://" + serverSettings.ListenEndpoint + serverSettings.ListenPath
-	return websocket.DefaultDialer.Dial(srvUrl, nil)
+	endpoint := serverSettings.ListenEndpoint
+	if serverSettings.Listener != nil {
+		endpoint = serverSettings.Listener.Addr().String()
+	}
+	dialer := *websocket.DefaultDialer
+	if strings.HasPrefix(endpoint, unixEndpointPrefix) {
+		socketPath := strings.TrimPrefix(endpoint, unixEndpointPrefix)
+		dialer.NetDial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", socketPath)
+		}
+		endpoint = "localhost"
+	}
+	srvUrl := "ws://" + endpoint + serverSettings.ListenPath
+	return dialer.Dial(srvUrl, nil)
 }
 
 func TestServerStartStop(t *testing.T) {
@@ -199,7 +219,7 @@ func TestServerAttachAcceptConnection(t *testing.T) {
 
 	// Prepare to attach OpAMP server to an HTTP Server created separately.
 	settings := Settings{Callbacks: callbacks}
-	srv := New(&sharedinternal.NopLogger{})
+	srv := NewWithLogger(logging.NopLogger{})
 	require.NotNil(t, srv)
 	handlerFunc, err := srv.Attach(settings)
 	require.NoError(t, err)
@@ -225,3 +245,554 @@ func TestServerAttachAcceptConnection(t *testing.T) {
 	conn.Close()
 	eventually(t, func() bool { return atomic.LoadInt32(&connectionCloseCalled) == 1 })
 }
+
+func postMessage(t *testing.T, serverSettings *StartSettings, msg *protobufs.AgentToServer) *protobufs.ServerToAgent {
+	data, err := proto.Marshal(msg)
+	require.NoError(t, err)
+
+	srvUrl := "http://" + serverSettings.ListenEndpoint + serverSettings.ListenPath
+	resp, err := http.Post(srvUrl, contentTypeProtobuf, bytes.NewReader(data))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.EqualValues(t, http.StatusOK, resp.StatusCode)
+	assert.EqualValues(t, contentTypeProtobuf, resp.Header.Get(headerContentType))
+
+	data, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var response protobufs.ServerToAgent
+	err = proto.Unmarshal(data, &response)
+	require.NoError(t, err)
+	return &response
+}
+
+func TestServerReceiveSendMessagePlainHTTP(t *testing.T) {
+	var rcvMsg atomic.Value
+	callbacks := CallbacksStruct{
+		OnMessageFunc: func(conn types.Connection, message *protobufs.AgentToServer) {
+			// Remember received message.
+			rcvMsg.Store(message)
+
+			// Send a response.
+			response := protobufs.ServerToAgent{
+				InstanceUid:  message.InstanceUid,
+				Capabilities: protobufs.ServerCapabilities_AcceptsStatus,
+			}
+			err := conn.Send(context.Background(), &response)
+			assert.NoError(t, err)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{Settings: Settings{Callbacks: callbacks}}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	// Send a message to the server using plain HTTP.
+	sendMsg := protobufs.AgentToServer{
+		InstanceUid: "12345678",
+	}
+	response := postMessage(t, settings, &sendMsg)
+
+	// Verify that the server received the message.
+	require.NotNil(t, rcvMsg.Load())
+	assert.True(t, proto.Equal(rcvMsg.Load().(proto.Message), &sendMsg))
+
+	// Verify the response.
+	assert.EqualValues(t, sendMsg.InstanceUid, response.InstanceUid)
+	assert.EqualValues(t, protobufs.ServerCapabilities_AcceptsStatus, response.Capabilities)
+}
+
+func TestServerPlainHTTPSession(t *testing.T) {
+	var connectedCalled int32
+	var connectionCloseCalled int32
+	var srvConn atomic.Value
+	callbacks := CallbacksStruct{
+		OnConnectedFunc: func(conn types.Connection) {
+			atomic.AddInt32(&connectedCalled, 1)
+			srvConn.Store(conn)
+		},
+		OnMessageFunc: func(conn types.Connection, message *protobufs.AgentToServer) {
+			// All requests of the same agent must use the same connection.
+			assert.EqualValues(t, srvConn.Load(), conn)
+		},
+		OnConnectionCloseFunc: func(conn types.Connection) {
+			atomic.AddInt32(&connectionCloseCalled, 1)
+			assert.EqualValues(t, srvConn.Load(), conn)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{
+		Settings: Settings{
+			Callbacks:          callbacks,
+			HTTPSessionTimeout: 200 * time.Millisecond,
+		},
+	}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	// Make a few requests. The server must respond with an empty message if
+	// the callback does not send anything.
+	sendMsg := protobufs.AgentToServer{InstanceUid: "12345678"}
+	for i := 0; i < 3; i++ {
+		response := postMessage(t, settings, &sendMsg)
+		assert.EqualValues(t, sendMsg.InstanceUid, response.InstanceUid)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&connectedCalled))
+	assert.EqualValues(t, 0, atomic.LoadInt32(&connectionCloseCalled))
+
+	// A message sent outside the request handling must be delivered in the
+	// response to the next request.
+	err := srvConn.Load().(types.Connection).Send(
+		context.Background(),
+		&protobufs.ServerToAgent{InstanceUid: sendMsg.InstanceUid, Capabilities: protobufs.ServerCapabilities_AcceptsStatus},
+	)
+	assert.NoError(t, err)
+	response := postMessage(t, settings, &sendMsg)
+	assert.EqualValues(t, protobufs.ServerCapabilities_AcceptsStatus, response.Capabilities)
+
+	// The session must be closed when the agent stops making requests.
+	eventually(t, func() bool { return atomic.LoadInt32(&connectionCloseCalled) == 1 })
+
+	// The next request starts a new session.
+	postMessage(t, settings, &sendMsg)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&connectedCalled))
+}
+
+func TestServerPlainHTTPSendReplacesPendingMessage(t *testing.T) {
+	var srvConn atomic.Value
+	callbacks := CallbacksStruct{
+		OnConnectedFunc: func(conn types.Connection) {
+			srvConn.Store(conn)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{Settings: Settings{Callbacks: callbacks}}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	sendMsg := protobufs.AgentToServer{InstanceUid: "12345678"}
+	postMessage(t, settings, &sendMsg)
+
+	// Send two remote configs before the next request, the second one has
+	// fewer config files.
+	conn := srvConn.Load().(types.Connection)
+	err := conn.Send(context.Background(), &protobufs.ServerToAgent{
+		InstanceUid: sendMsg.InstanceUid,
+		RemoteConfig: &protobufs.AgentRemoteConfig{
+			Config: &protobufs.AgentConfigMap{
+				ConfigMap: map[string]*protobufs.AgentConfigFile{
+					"a": {Body: []byte("a")},
+					"b": {Body: []byte("b")},
+				},
+			},
+			ConfigHash: []byte{1},
+		},
+	})
+	assert.NoError(t, err)
+	last := &protobufs.ServerToAgent{
+		InstanceUid: sendMsg.InstanceUid,
+		RemoteConfig: &protobufs.AgentRemoteConfig{
+			Config: &protobufs.AgentConfigMap{
+				ConfigMap: map[string]*protobufs.AgentConfigFile{
+					"a": {Body: []byte("a2")},
+				},
+			},
+			ConfigHash: []byte{2},
+		},
+	}
+	err = conn.Send(context.Background(), last)
+	assert.NoError(t, err)
+
+	// Only the last message must be delivered, without the stale config file.
+	response := postMessage(t, settings, &sendMsg)
+	assert.True(t, proto.Equal(last, response))
+}
+
+func TestServerPlainHTTPRejectsInvalidContentType(t *testing.T) {
+	// Start a server.
+	settings := &StartSettings{}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	srvUrl := "http://" + settings.ListenEndpoint + settings.ListenPath
+	resp, err := http.Post(srvUrl, "text/plain", strings.NewReader("hello"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestServerPlainHTTPAcceptsContentTypeParameters(t *testing.T) {
+	// Start a server.
+	settings := &StartSettings{}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	data, err := proto.Marshal(&protobufs.AgentToServer{InstanceUid: "agent"})
+	require.NoError(t, err)
+
+	srvUrl := "http://" + settings.ListenEndpoint + settings.ListenPath
+	resp, err := http.Post(srvUrl, "Application/X-Protobuf; charset=binary", bytes.NewReader(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestServerPlainHTTPRejectsLargeBody(t *testing.T) {
+	var connectedCalled int32
+	callbacks := CallbacksStruct{
+		OnConnectedFunc: func(conn types.Connection) {
+			atomic.AddInt32(&connectedCalled, 1)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{
+		Settings: Settings{Callbacks: callbacks, MaxHTTPRequestBodySize: 100},
+	}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	// Send a message that is larger than the limit.
+	data, err := proto.Marshal(&protobufs.AgentToServer{
+		InstanceUid: "agent",
+		StatusReport: &protobufs.StatusReport{
+			EffectiveConfig: &protobufs.EffectiveConfig{Hash: make([]byte, 200)},
+		},
+	})
+	require.NoError(t, err)
+
+	srvUrl := "http://" + settings.ListenEndpoint + settings.ListenPath
+	resp, err := http.Post(srvUrl, contentTypeProtobuf, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&connectedCalled))
+}
+
+// failingReader returns the data and then fails instead of returning io.EOF.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestServerPlainHTTPReadErrorAtLimit(t *testing.T) {
+	srv := NewWithLogger(nil)
+	srv.settings.MaxHTTPRequestBodySize = 100
+
+	// A body that fails after exactly the limit is not too large, but cannot
+	// be read.
+	req := httptest.NewRequest(
+		http.MethodPost, "/", io.NopCloser(&failingReader{data: make([]byte, 100)}),
+	)
+	req.Header.Set(headerContentType, contentTypeProtobuf)
+	w := httptest.NewRecorder()
+	srv.handlePlainHTTPRequest(w, req)
+	assert.EqualValues(t, http.StatusBadRequest, w.Code)
+}
+
+func TestServerPlainHTTPRejectsEmptyInstanceUid(t *testing.T) {
+	var connectedCalled int32
+	callbacks := CallbacksStruct{
+		OnConnectedFunc: func(conn types.Connection) {
+			atomic.AddInt32(&connectedCalled, 1)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{Settings: Settings{Callbacks: callbacks}}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	// Send a message without the instance UID.
+	data, err := proto.Marshal(&protobufs.AgentToServer{StatusReport: &protobufs.StatusReport{}})
+	require.NoError(t, err)
+
+	srvUrl := "http://" + settings.ListenEndpoint + settings.ListenPath
+	resp, err := http.Post(srvUrl, contentTypeProtobuf, bytes.NewReader(data))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.EqualValues(t, http.StatusBadRequest, resp.StatusCode)
+
+	// No session must be created.
+	assert.EqualValues(t, 0, atomic.LoadInt32(&connectedCalled))
+	srv.httpSessionsMutex.Lock()
+	assert.Empty(t, srv.httpSessions)
+	srv.httpSessionsMutex.Unlock()
+}
+
+func TestServerPingTimeoutClosesConnection(t *testing.T) {
+	var connectionCloseCalled int32
+	callbacks := CallbacksStruct{
+		OnConnectionCloseFunc: func(conn types.Connection) {
+			atomic.StoreInt32(&connectionCloseCalled, 1)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{
+		Settings: Settings{
+			Callbacks:    callbacks,
+			PingInterval: 50 * time.Millisecond,
+			PongTimeout:  50 * time.Millisecond,
+		},
+	}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	// Connect using a WebSocket client that keeps reading and thus responds to pings.
+	conn, _, err := dialClient(settings)
+	require.NoError(t, err)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The connection is healthy and must stay open.
+	time.Sleep(300 * time.Millisecond)
+	assert.EqualValues(t, 0, atomic.LoadInt32(&connectionCloseCalled))
+	conn.Close()
+	eventually(t, func() bool { return atomic.LoadInt32(&connectionCloseCalled) == 1 })
+	atomic.StoreInt32(&connectionCloseCalled, 0)
+
+	// Connect using a WebSocket client that does not read and thus never
+	// responds to pings, which looks like a half-open connection to the server.
+	conn, _, err = dialClient(settings)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The server must detect that the agent does not respond and close the connection.
+	eventually(t, func() bool { return atomic.LoadInt32(&connectionCloseCalled) == 1 })
+}
+
+func TestServerAgentDisconnect(t *testing.T) {
+	var rcvDisconnect atomic.Value
+	var connectionCloseCalled int32
+	callbacks := CallbacksStruct{
+		OnAgentDisconnectFunc: func(conn types.Connection, instanceUid string) {
+			rcvDisconnect.Store(instanceUid)
+		},
+		OnConnectionCloseFunc: func(conn types.Connection) {
+			atomic.StoreInt32(&connectionCloseCalled, 1)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{Settings: Settings{Callbacks: callbacks}}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	// Connect using a WebSocket client.
+	conn, _, err := dialClient(settings)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Send a message without AgentDisconnect.
+	sendMsg := protobufs.AgentToServer{InstanceUid: "12345678"}
+	bytes, err := proto.Marshal(&sendMsg)
+	require.NoError(t, err)
+	err = conn.WriteMessage(websocket.BinaryMessage, bytes)
+	require.NoError(t, err)
+
+	// Send a message with AgentDisconnect.
+	sendMsg.AgentDisconnect = &protobufs.AgentDisconnect{}
+	bytes, err = proto.Marshal(&sendMsg)
+	require.NoError(t, err)
+	err = conn.WriteMessage(websocket.BinaryMessage, bytes)
+	require.NoError(t, err)
+
+	// Only the second message must result in OnAgentDisconnect.
+	eventually(t, func() bool { return rcvDisconnect.Load() != nil })
+	assert.EqualValues(t, sendMsg.InstanceUid, rcvDisconnect.Load())
+	assert.EqualValues(t, 0, atomic.LoadInt32(&connectionCloseCalled))
+}
+
+func TestServerAgentDisconnectPlainHTTP(t *testing.T) {
+	var rcvDisconnect atomic.Value
+	var connectionCloseCalled int32
+	callbacks := CallbacksStruct{
+		OnAgentDisconnectFunc: func(conn types.Connection, instanceUid string) {
+			rcvDisconnect.Store(instanceUid)
+		},
+		OnConnectionCloseFunc: func(conn types.Connection) {
+			atomic.StoreInt32(&connectionCloseCalled, 1)
+		},
+	}
+
+	// Start a server.
+	settings := &StartSettings{Settings: Settings{Callbacks: callbacks}}
+	srv := startServer(t, settings)
+	defer srv.Stop(context.Background())
+
+	sendMsg := protobufs.AgentToServer{InstanceUid: "12345678"}
+	postMessage(t, settings, &sendMsg)
+	assert.Nil(t, rcvDisconnect.Load())
+
+	// The AgentDisconnect must end the session.
+	sendMsg.AgentDisconnect = &protobufs.AgentDisconnect{}
+	postMessage(t, settings, &sendMsg)
+	assert.EqualValues(t, sendMsg.InstanceUid, rcvDisconnect.Load())
+	assert.EqualValues(t, 1, atomic.LoadInt32(&connectionCloseCalled))
+}
+
+// logEntry is a message recorded by recordingLogger.
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordingLogger is a logging.Logger that records the messages.
+type recordingLogger struct {
+	mutex   *sync.Mutex
+	entries *[]logEntry
+	fields  []logging.Field
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{mutex: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...logging.Field) { l.record("debug", msg, fields) }
+func (l *recordingLogger) Info(msg string, fields ...logging.Field)  { l.record("info", msg, fields) }
+func (l *recordingLogger) Warn(msg string, fields ...logging.Field)  { l.record("warn", msg, fields) }
+func (l *recordingLogger) Error(msg string, fields ...logging.Field) { l.record("error", msg, fields) }
+
+func (l *recordingLogger) With(fields ...logging.Field) logging.Logger {
+	all := append(append([]logging.Field{}, l.fields...), fields...)
+	return &recordingLogger{mutex: l.mutex, entries: l.entries, fields: all}
+}
+
+func (l *recordingLogger) record(level string, msg string, fields []logging.Field) {
+	entry := logEntry{level: level, msg: msg, fields: map[string]interface{}{}}
+	for _, field := range append(append([]logging.Field{}, l.fields...), fields...) {
+		entry.fields[field.Key] = field.Value
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	*l.entries = append(*l.entries, entry)
+}
+
+// find returns the first recorded entry with the specified message.
+func (l *recordingLogger) find(msg string) *logEntry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, entry := range *l.entries {
+		if entry.msg == msg {
+			return &entry
+		}
+	}
+	return nil
+}
+
+func TestServerLogsAgentFields(t *testing.T) {
+	logger := newRecordingLogger()
+
+	// Start a server.
+	settings := &StartSettings{
+		ListenEndpoint: testhelpers.GetAvailableLocalAddress(),
+		ListenPath:     "/",
+	}
+	srv := NewWithLogger(logger)
+	require.NoError(t, srv.Start(*settings))
+	defer srv.Stop(context.Background())
+
+	// Connect using a WebSocket client.
+	conn, _, err := dialClient(settings)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The connection must be logged with the address of the agent.
+	eventually(t, func() bool { return logger.find("Agent connected") != nil })
+	connected := logger.find("Agent connected")
+	assert.EqualValues(t, "info", connected.level)
+	assert.EqualValues(t, conn.LocalAddr().String(), connected.fields[logging.KeyRemoteAddr])
+
+	// Send a message and close the connection.
+	bytes, err := proto.Marshal(&protobufs.AgentToServer{InstanceUid: "12345678"})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, bytes))
+	err = conn.WriteMessage(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+	)
+	require.NoError(t, err)
+
+	// The disconnection must be logged with the instance UID as well.
+	eventually(t, func() bool { return logger.find("Agent disconnected") != nil })
+	disconnected := logger.find("Agent disconnected")
+	assert.EqualValues(t, "info", disconnected.level)
+	assert.EqualValues(t, conn.LocalAddr().String(), disconnected.fields[logging.KeyRemoteAddr])
+	assert.EqualValues(t, "12345678", disconnected.fields[logging.KeyInstanceUid])
+}
+
+func TestServerListenUnixSocket(t *testing.T) {
+	var connected int64
+	callbacks := CallbacksStruct{
+		OnConnectedFunc: func(conn types.Connection) {
+			atomic.StoreInt64(&connected, 1)
+		},
+	}
+
+	// Start a server on a Unix domain socket.
+	settings := &StartSettings{
+		Settings:       Settings{Callbacks: callbacks},
+		ListenEndpoint: "unix://" + filepath.Join(t.TempDir(), "opamp.sock"),
+	}
+	srv := startServer(t, settings)
+
+	// Connect to the server.
+	conn, _, err := dialClient(settings)
+	require.NoError(t, err)
+	defer conn.Close()
+	eventually(t, func() bool { return atomic.LoadInt64(&connected) == 1 })
+
+	// Stop must remove the socket.
+	err = srv.Stop(context.Background())
+	assert.NoError(t, err)
+	_, err = os.Stat(strings.TrimPrefix(settings.ListenEndpoint, unixEndpointPrefix))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestServerListener(t *testing.T) {
+	var connected int64
+	callbacks := CallbacksStruct{
+		OnConnectedFunc: func(conn types.Connection) {
+			atomic.StoreInt64(&connected, 1)
+		},
+	}
+
+	// Start a server on the supplied listener.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	settings := &StartSettings{
+		Settings: Settings{Callbacks: callbacks},
+		Listener: ln,
+	}
+	srv := startServer(t, settings)
+
+	// Connect to the server.
+	conn, _, err := dialClient(settings)
+	require.NoError(t, err)
+	defer conn.Close()
+	eventually(t, func() bool { return atomic.LoadInt64(&connected) == 1 })
+
+	// Stop must close the listener.
+	err = srv.Stop(context.Background())
+	assert.NoError(t, err)
+	_, err = ln.Accept()
+	assert.Error(t, err)
+}
@@ -17,7 +17,8 @@ type Callbacks interface {
 	// The following callbacks will never be called concurrently for the same
 	// connection. They may be called concurrently for different connections.
 
-	// OnConnecting is called when there is a new incoming connection.
+	// OnConnecting is called when there is a new incoming connection. For agents
+	// that use plain HTTP transport it is called for every HTTP request.
 	// The handler can examine the request and either accept or reject the connection.
 	// To accept:
 	//   Return ConnectionResponse with Accept=true.
@@ -31,14 +32,25 @@ type Callbacks interface {
 
 	// OnConnected is called when the WebSocket connection is successfully established
 	// after OnConnecting() returns and the HTTP connection is upgraded to WebSocket.
+	// For agents that use plain HTTP transport it is called before the first
+	// OnMessage() of the agent's session (see OnConnectionClose).
 	OnConnected(conn Connection)
 
 	// OnMessage is called when a message is received from the connection. Can happen
 	// only after OnConnected().
 	OnMessage(conn Connection, message *protobufs.AgentToServer)
 
+	// OnAgentDisconnect is called after OnMessage() if the message has the
+	// AgentDisconnect field set, which means that the agent with the specified
+	// instance UID is shutting down gracefully and will send no more messages.
+	OnAgentDisconnect(conn Connection, instanceUid string)
+
 	// OnConnectionClose is called when the WebSocket connection is closed.
-	// Typically, preceded by OnDisconnect() unless the client misbehaves or the
+	// Typically, preceded by OnAgentDisconnect() unless the client misbehaves or the
 	// connection is lost.
+	// For agents that use plain HTTP transport all requests with the same
+	// instance UID share one session and the same Connection. The session is
+	// closed after OnAgentDisconnect(), when the agent makes no requests for the
+	// duration of Settings.HTTPSessionTimeout or when the server is stopped.
 	OnConnectionClose(conn Connection)
 }
@@ -6,11 +6,18 @@ import (
 	"github.com/open-telemetry/opamp-go/protobufs"
 )
 
-// Connection represents one OpAMP WebSocket connections.
+// Connection represents one OpAMP WebSocket connection or one session of an
+// agent that uses plain HTTP transport.
 // The implementation MUST be a comparable type so that it can be used as a map key.
 type Connection interface {
 	// Send a message. Should not be called concurrently for the same Connection instance.
 	// Blocks until the message is sent.
+	// For plain HTTP transport the message is written as the response to the
+	// request that is being handled by OnMessage. If called outside OnMessage
+	// (or called again) the message is sent in the response to the next request
+	// of the agent. In that case only the last message sent before the next
+	// request is delivered, so each message must contain everything the agent
+	// needs to know.
 	// Should return as soon as possible if the ctx is cancelled.
 	Send(ctx context.Context, message *protobufs.ServerToAgent) error
 }